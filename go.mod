@@ -11,6 +11,7 @@ require (
 	github.com/docker/compose/v2 v2.1.1
 	github.com/docker/docker v20.10.7+incompatible
 	github.com/fatih/color v1.13.0
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/hashicorp/go-hclog v1.0.0
 	github.com/hexops/gotextdiff v1.0.3
 	github.com/jackc/pgconn v1.10.0
@@ -24,6 +25,7 @@ require (
 	github.com/posener/complete v1.2.3
 	github.com/sebdah/goldie/v2 v2.5.3
 	github.com/stretchr/testify v1.7.0
+	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c
 	golang.org/x/sys v0.0.0-20211025201205-69cdffdb9359
 )
 
@@ -137,7 +139,6 @@ require (
 	golang.org/x/crypto v0.0.0-20210711020723-a769d52b0f97 // indirect
 	golang.org/x/net v0.0.0-20210614182718-04defd469f4e // indirect
 	golang.org/x/oauth2 v0.0.0-20210402161424-2e8d93401602 // indirect
-	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c // indirect
 	golang.org/x/term v0.0.0-20210220032956-6a3ed077a48d // indirect
 	golang.org/x/text v0.3.6 // indirect
 	golang.org/x/time v0.0.0-20210220033141-f8bda1e9f3ba // indirect
@@ -3,6 +3,7 @@ package cli
 import (
 	"context"
 	"os"
+	"strings"
 
 	"github.com/cockroachdb/errors"
 	"github.com/hashicorp/go-hclog"
@@ -30,6 +31,13 @@ type baseCommand struct {
 
 	Config *config.Config
 	Squire *squire.Squire
+
+	//---------------------------------------------------------------
+	// Global flags
+
+	// db is the name of the database to operate on if the project has
+	// multiple databases. See squire.Squire.Database.
+	db string
 }
 
 // Close implements io.Closer. This should be called to gracefully clean up
@@ -80,9 +88,55 @@ func (c *baseCommand) Init(opts ...Option) error {
 	}
 	c.Squire = sq
 
+	// Choose our database if the project has multiple.
+	dbs := sq.Databases()
+	switch {
+	case c.db != "":
+		c.Squire, err = sq.Database(c.db)
+		if err != nil {
+			return err
+		}
+
+	case len(dbs) == 1:
+		c.Squire, err = sq.Database(dbs[0])
+		if err != nil {
+			return err
+		}
+
+	case len(dbs) > 1 && !baseCfg.AllDatabases:
+		return errors.WithDetailf(
+			errors.New("multiple databases are configured, choose one with -db"),
+			strings.TrimSpace(errDetailChooseDatabase),
+			strings.Join(dbs, ", "),
+		)
+	}
+
 	return nil
 }
 
+// databases returns the Squire instances for every database the command
+// should operate on. This is the database chosen with "-db" if it is set,
+// otherwise every database in the project. Commands that use this should
+// call Init with WithAllDatabases.
+func (c *baseCommand) databases() ([]*squire.Squire, error) {
+	dbs := c.Squire.Databases()
+	if c.Squire.DatabaseName() != "" || len(dbs) == 0 {
+		return []*squire.Squire{c.Squire}, nil
+	}
+
+	result := make([]*squire.Squire, len(dbs))
+	for i, name := range dbs {
+		sq, err := c.Squire.Database(name)
+		if err != nil {
+			return nil, err
+		}
+
+		result[i] = sq
+	}
+
+	return result, nil
+}
+
 // loadConfig loads the configuration and sets it on the base.
 func (c *baseCommand) loadConfig() error {
 	var opts []config.Option
@@ -121,14 +175,18 @@ func (c *baseCommand) loadConfig() error {
 
 // exitError should be called by commands to exit with an error.
 func (c *baseCommand) exitError(err error) int {
+	c.printError(err)
+	return 1
+}
+
+// printError prints the error along with any details to stderr.
+func (c *baseCommand) printError(err error) {
 	colorError.Fprintf(os.Stderr, "%s\n", err.Error())
 
 	// If this is a cockroach error with details, then output the details.
 	if v := errors.FlattenDetails(err); v != "" {
 		colorErrorDetail.Fprintf(os.Stderr, "\n%s\n", v)
 	}
-
-	return 1
 }
 
 // flagSet creates the flags for this command. The callback should be used
@@ -140,6 +198,16 @@ func (c *baseCommand) flagSet(bit flagSetBit, f func(*flag.Sets)) *flag.Sets {
 		f(set)
 	}
 
+	if bit&flagSetDefault != 0 {
+		global := set.NewSet("Global Options")
+		global.StringVar(&flag.StringVar{
+			Name:   "db",
+			Target: &c.db,
+			Usage: "The name of the database to operate on if the project " +
+				"has multiple databases (see the \"databases\" configuration).",
+		})
+	}
+
 	return set
 }
 
@@ -172,8 +240,25 @@ func WithFlags(f *flag.Sets, outArgs *[]string) Option {
 	}
 }
 
+// WithAllDatabases allows the command to run without "-db" when the project
+// has multiple databases. The command should then operate on every database,
+// see baseCommand.databases.
+func WithAllDatabases() Option {
+	return func(c *baseConfig) { c.AllDatabases = true }
+}
+
 type baseConfig struct {
-	Args        []string
-	Flags       *flag.Sets
-	FlagOutArgs *[]string
+	Args         []string
+	Flags        *flag.Sets
+	FlagOutArgs  *[]string
+	AllDatabases bool
 }
+
+const (
+	errDetailChooseDatabase = `
+This project has multiple databases configured with the "databases" setting,
+and this command operates on a single database. Choose one with "-db <name>".
+
+Databases: %s
+`
+)
@@ -23,6 +23,7 @@ func (c *ConfigCommand) Run(args []string) int {
 	if err := c.Init(
 		WithArgs(args),
 		WithFlags(c.Flags(), nil),
+		WithAllDatabases(),
 	); err != nil {
 		return c.exitError(err)
 	}
@@ -56,6 +57,25 @@ func (c *ConfigCommand) Run(args []string) int {
 		return c.exitError(err)
 	}
 
+	// Show the Docker Compose files since they configure the dev container
+	// as well. These are comments so the output is still valid Cue.
+	if !c.def {
+		files, err := c.Squire.ComposeFiles()
+		if err != nil {
+			return c.exitError(err)
+		}
+
+		if len(files) == 0 {
+			fmt.Println("// Docker Compose: none found, using the default dev container.")
+		} else {
+			fmt.Println("// Docker Compose files (merged in order):")
+			for _, f := range files {
+				fmt.Printf("//   %s\n", f)
+			}
+		}
+		fmt.Println()
+	}
+
 	fmt.Println(string(bs))
 	return 0
 }
@@ -114,6 +134,12 @@ Usage: squire config [options]
   This is a fully valid configuration file you can start with. The "--json"
   flag can be specified to output in JSON.
 
+  The Docker Compose files used for the dev container, if any, are listed
+  in a comment before the configuration. These are found using the same
+  rules as Docker Compose: COMPOSE_FILE if set, otherwise compose.yaml
+  (or compose.yml, docker-compose.yaml, docker-compose.yml) and its
+  override file in the current directory or the nearest parent directory.
+
   The configuration file should be saved to .squire, .squire.cue, or
   .squire.json (with only the final filename being json-formatted and
   the others being in Cue). Only one file will be loaded, in the order
@@ -9,6 +9,7 @@ import (
 	"github.com/cockroachdb/errors"
 	"github.com/posener/complete"
 
+	"github.com/mitchellh/squire/internal/dbcontainer"
 	"github.com/mitchellh/squire/internal/pkg/exec"
 	"github.com/mitchellh/squire/internal/pkg/flag"
 )
@@ -17,6 +18,7 @@ type ConsoleCommand struct {
 	*baseCommand
 
 	production bool
+	test       bool
 }
 
 func (c *ConsoleCommand) Run(args []string) int {
@@ -33,6 +35,25 @@ func (c *ConsoleCommand) Run(args []string) int {
 		return c.exitError(err)
 	}
 
+	// If test, use the test container kept from "squire test -keep"
+	if c.test {
+		ctr, err = c.Squire.TestContainer(c.Ctx)
+		if err != nil {
+			return c.exitError(err)
+		}
+
+		st, err := ctr.Status(c.Ctx)
+		if err != nil {
+			return c.exitError(err)
+		}
+		if st.State != dbcontainer.Running {
+			return c.exitError(errors.WithDetail(
+				errors.New("test database is not running"),
+				strings.TrimSpace(errDetailNoTestContainer),
+			))
+		}
+	}
+
 	// Get the URI
 	uri := ctr.ConnURI()
 
@@ -73,6 +94,13 @@ func (c *ConsoleCommand) Flags() *flag.Sets {
 			Usage:   "Use the production database.",
 			Aliases: []string{"p"},
 		})
+
+		f.BoolVar(&flag.BoolVar{
+			Name:    "test",
+			Target:  &c.test,
+			Default: false,
+			Usage:   "Use the test database kept by \"squire test -keep\".",
+		})
 	})
 }
 
@@ -100,6 +128,9 @@ Usage: squire console [options]
   This can also open a console to the production database by
   specifying the "-production" flag.
 
+  The "-test" flag opens a console to the test database left running
+  by "squire test -keep". This is useful for debugging failing tests.
+
 ` + c.Flags().Help())
 }
 
@@ -108,5 +139,11 @@ const (
 "psql" was not found installed on your system. "squire console" requires
 "psql" to be available. This is usually found by installing the
 default PostgreSQL package for your operating system.
+`
+
+	errDetailNoTestContainer = `
+The "-test" flag connects to the test database that is kept running by
+"squire test -keep". No running test database was found. Please run
+"squire test -keep" first.
 `
 )
@@ -139,6 +139,15 @@ func (c *DeployCommand) Run(args []string) int {
 		return c.exitError(err)
 	}
 
+	// Record production deployments so that "squire status" can detect
+	// changes made since. The deployment already succeeded so this
+	// doesn't fail the command.
+	if c.production {
+		if err := c.Squire.RecordDeployment(ctx, targetURI); err != nil {
+			L.Warn("error recording the deployment, drift can't be detected", "err", err)
+		}
+	}
+
 	colorSuccess.Println("Changes successfully deployed.")
 	return 0
 }
@@ -205,6 +214,9 @@ Usage: squire deploy [options]
   having a migration path. Deploy can be used to test a final schema change,
   and then to finally deploy it to production.
 
+  Deployments to production are recorded so that "squire status" can report
+  whether production has changed since.
+
 ` + c.Flags().Help())
 }
 
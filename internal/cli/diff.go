@@ -109,6 +109,12 @@ Usage: squire diff [options]
   the deployed schema in the development container from "squire up". In this
   case, the development container must be up and running.
 
+  The current SQL files are applied to a temporary database to create the
+  diff. If the development container is running, this is a new shadow
+  database ("squire_shadow_diff_<time>") within it that is dropped when the
+  diff completes.
+  Otherwise, a temporary clone of the development container is started.
+
   WARNING: The diff is not perfect and does not support all PostgreSQL
   functionality. All common operations are fully supported but there are
   various edges of PostgreSQL that aren't covered. Always manually verify
@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/posener/complete"
+
+	"github.com/mitchellh/squire/internal/dbcontainer"
+	"github.com/mitchellh/squire/internal/pkg/flag"
+	"github.com/mitchellh/squire/internal/squire"
+)
+
+type GCCommand struct {
+	*baseCommand
+
+	all    bool
+	minAge time.Duration
+	dryRun bool
+}
+
+func (c *GCCommand) Run(args []string) int {
+	ctx := c.Ctx
+
+	if err := c.Init(
+		WithArgs(args),
+		WithFlags(c.Flags(), nil),
+	); err != nil {
+		return c.exitError(err)
+	}
+
+	count := 0
+	if err := c.Squire.GC(ctx, &squire.GCOptions{
+		MinAge: c.minAge,
+		All:    c.all,
+		DryRun: c.dryRun,
+		Callback: func(info *dbcontainer.CloneInfo) {
+			count++
+			age := time.Since(info.Created).Round(time.Second)
+			fmt.Printf("%s (created %s ago)\n", info.Project, age)
+		},
+	}); err != nil {
+		return c.exitError(err)
+	}
+
+	switch {
+	case count == 0:
+		fmt.Println("No orphaned containers found.")
+	case c.dryRun:
+		fmt.Printf("\n%d container(s) would be destroyed.\n", count)
+	default:
+		colorSuccess.Printf("\nDestroyed %d container(s).\n", count)
+	}
+
+	return 0
+}
+
+func (c *GCCommand) Flags() *flag.Sets {
+	return c.flagSet(flagSetDefault, func(sets *flag.Sets) {
+		f := sets.NewSet("Command Options")
+
+		f.BoolVar(&flag.BoolVar{
+			Name:    "all",
+			Target:  &c.all,
+			Default: false,
+			Usage: "Also destroy the kept test container and the pool " +
+				"containers.",
+		})
+
+		f.DurationVar(&flag.DurationVar{
+			Name:    "min-age",
+			Target:  &c.minAge,
+			Default: 10 * time.Minute,
+			Usage: "Only destroy containers at least this old so that " +
+				"containers in use by running commands are left alone.",
+		})
+
+		f.BoolVar(&flag.BoolVar{
+			Name:    "dry-run",
+			Target:  &c.dryRun,
+			Default: false,
+			Usage:   "Only list the containers that would be destroyed.",
+		})
+	})
+}
+
+func (c *GCCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (c *GCCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *GCCommand) Synopsis() string {
+	return "Destroy containers left behind by interrupted commands"
+}
+
+func (c *GCCommand) Help() string {
+	return formatHelp(`
+Usage: squire gc [options]
+
+  Destroy clones of the dev container that were left behind.
+
+  Commands such as "squire diff" and "squire test" create temporary clones
+  of the dev container and destroy them when they complete, even when they
+  are interrupted. If Squire crashes or is killed, the clones may be left
+  running. This command finds them by their "com.mitchellh.squire.clone"
+  Docker label, which has the name of the dev project they were cloned
+  from, and destroys them. Shadow databases left behind within the dev
+  container are dropped as well if it is running.
+
+  By default, only clones created for a single command are destroyed. Use
+  "-all" to also destroy the test container kept by "squire test -keep" and
+  the pool containers (see "squire pool"). The dev container itself is never
+  destroyed; use "squire down" for that.
+
+` + c.Flags().Help())
+}
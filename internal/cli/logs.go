@@ -0,0 +1,156 @@
+package cli
+
+import (
+	"os"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/posener/complete"
+
+	"github.com/mitchellh/squire/internal/dbcontainer"
+	"github.com/mitchellh/squire/internal/pkg/flag"
+)
+
+type LogsCommand struct {
+	*baseCommand
+
+	follow bool
+	test   bool
+	clone  string
+}
+
+func (c *LogsCommand) Run(args []string) int {
+	ctx := c.Ctx
+
+	if err := c.Init(
+		WithArgs(args),
+		WithFlags(c.Flags(), nil),
+	); err != nil {
+		return c.exitError(err)
+	}
+
+	// Get our container
+	ctr, err := c.Squire.Container()
+	if err != nil {
+		return c.exitError(err)
+	}
+
+	switch {
+	case c.test:
+		// The test container kept from "squire test -keep"
+		ctr, err = c.Squire.TestContainer(ctx)
+
+	case c.clone != "":
+		// Clones are started on a random port so we need to adopt it
+		ctr, err = ctr.Clone(c.clone)
+		if err == nil {
+			_, err = ctr.Adopt(ctx)
+		}
+	}
+	if err != nil {
+		return c.exitError(err)
+	}
+
+	st, err := ctr.Status(ctx)
+	if err != nil {
+		return c.exitError(err)
+	}
+	if st.State == dbcontainer.NotCreated {
+		detail := errDetailLogsNotCreated
+		switch {
+		case c.test:
+			detail = errDetailNoTestContainer
+		case c.clone != "":
+			detail = errDetailLogsNoClone
+		}
+
+		return c.exitError(errors.WithDetail(
+			errors.New("database container does not exist"),
+			strings.TrimSpace(detail),
+		))
+	}
+
+	if err := ctr.Logs(ctx, os.Stdout, dbcontainer.LogOptions{
+		Follow: c.follow,
+	}); err != nil && ctx.Err() == nil {
+		return c.exitError(err)
+	}
+
+	return 0
+}
+
+func (c *LogsCommand) Flags() *flag.Sets {
+	return c.flagSet(flagSetDefault, func(sets *flag.Sets) {
+		f := sets.NewSet("Command Options")
+
+		f.BoolVar(&flag.BoolVar{
+			Name:    "follow",
+			Target:  &c.follow,
+			Default: false,
+			Usage:   "Keep streaming new log lines until interrupted.",
+			Aliases: []string{"f"},
+		})
+
+		f.BoolVar(&flag.BoolVar{
+			Name:    "test",
+			Target:  &c.test,
+			Default: false,
+			Usage:   "Show the logs of the test database kept by \"squire test -keep\".",
+		})
+
+		f.StringVar(&flag.StringVar{
+			Name:    "clone",
+			Target:  &c.clone,
+			Default: "",
+			Usage: "Show the logs of the clone with this name, such as a diff " +
+				"clone left behind by an interrupted command (see \"squire status\").",
+		})
+	})
+}
+
+func (c *LogsCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (c *LogsCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *LogsCommand) Synopsis() string {
+	return "Show the PostgreSQL server logs of the dev database"
+}
+
+func (c *LogsCommand) Help() string {
+	return formatHelp(`
+Usage: squire logs [options]
+
+  Show the PostgreSQL server logs of the dev database.
+
+  The server log often has more information about errors than the error
+  returned to Squire, such as an extension that isn't installed in the
+  container image or running out of shared memory. When "squire reset"
+  fails, the last lines of the log are shown with the error.
+
+  The "-test" flag shows the logs of the test database left running by
+  "squire test -keep". The "-clone" flag shows the logs of any other clone
+  by name, such as a diff clone left behind by an interrupted command;
+  "squire status" lists these clones. Use "-f" to keep streaming new lines.
+
+  The logs aren't available with the "external" backend since Squire
+  doesn't run that server.
+
+` + c.Flags().Help())
+}
+
+const (
+	errDetailLogsNotCreated = `
+The dev database container hasn't been created, so there are no logs to
+show. Please run "squire up" to create it.
+`
+
+	errDetailLogsNoClone = `
+The clone with the given name doesn't exist. The name is the part of the
+clone's name after the dev container's name, i.e. "diff-1634567890".
+Run "squire status" to list the clones that exist.
+`
+)
@@ -103,6 +103,12 @@ func Commands(
 			}, nil
 		},
 
+		"gc": func() (cli.Command, error) {
+			return &GCCommand{
+				baseCommand: baseCommand,
+			}, nil
+		},
+
 		"schema": func() (cli.Command, error) {
 			return &SchemaCommand{
 				baseCommand: baseCommand,
@@ -145,12 +151,48 @@ func Commands(
 			}, nil
 		},
 
+		"logs": func() (cli.Command, error) {
+			return &LogsCommand{
+				baseCommand: baseCommand,
+			}, nil
+		},
+
+		"pool": func() (cli.Command, error) {
+			return &PoolCommand{
+				baseCommand: baseCommand,
+			}, nil
+		},
+
+		"pool status": func() (cli.Command, error) {
+			return &PoolStatusCommand{
+				baseCommand: baseCommand,
+			}, nil
+		},
+
+		"pool drain": func() (cli.Command, error) {
+			return &PoolDrainCommand{
+				baseCommand: baseCommand,
+			}, nil
+		},
+
+		"status": func() (cli.Command, error) {
+			return &StatusCommand{
+				baseCommand: baseCommand,
+			}, nil
+		},
+
 		"url": func() (cli.Command, error) {
 			return &URLCommand{
 				baseCommand: baseCommand,
 			}, nil
 		},
 
+		"watch": func() (cli.Command, error) {
+			return &WatchCommand{
+				baseCommand: baseCommand,
+			}, nil
+		},
+
 		"version": func() (cli.Command, error) {
 			return &VersionCommand{
 				baseCommand: baseCommand,
@@ -0,0 +1,191 @@
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
+
+	"github.com/mitchellh/squire/internal/pkg/flag"
+	"github.com/mitchellh/squire/internal/pkg/stdcapture"
+)
+
+type PoolCommand struct {
+	*baseCommand
+}
+
+func (c *PoolCommand) Run(args []string) int {
+	return cli.RunResultHelp
+}
+
+func (c *PoolCommand) Synopsis() string {
+	return "Manage the pool of clone containers used for diffs and tests"
+}
+
+func (c *PoolCommand) Help() string {
+	return formatHelp(`
+Usage: squire pool <subcommand> [options]
+
+  Manage the pool of clone containers used for diffs and tests.
+
+  If "dev.pool_size" is set in the configuration, Squire keeps that many
+  clones of the dev container running. Commands such as "squire diff" and
+  "squire test" lease a clone from the pool, reset it, and return it when
+  they're done instead of starting and destroying a new container every
+  time. The pool is started the first time a clone is leased. If every
+  clone is in use, Squire falls back to what it does without a pool.
+
+  Use "squire pool status" to view the pool and "squire pool drain" to
+  destroy it.
+
+`)
+}
+
+type PoolStatusCommand struct {
+	*baseCommand
+}
+
+func (c *PoolStatusCommand) Run(args []string) int {
+	ctx := c.Ctx
+
+	if err := c.Init(
+		WithArgs(args),
+		WithFlags(c.Flags(), nil),
+	); err != nil {
+		return c.exitError(err)
+	}
+
+	pool, err := c.Squire.Pool()
+	if err != nil {
+		return c.exitError(err)
+	}
+	if pool.Size() == 0 {
+		fmt.Println("The pool is disabled. Set \"dev.pool_size\" to enable it.")
+		return 0
+	}
+
+	sts, err := pool.Status(ctx)
+	if err != nil {
+		return c.exitError(err)
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"#", "state", "port", "leased"})
+	for i, st := range sts {
+		port := ""
+		if st.Port > 0 {
+			port = fmt.Sprint(st.Port)
+		}
+
+		t.AppendRow(table.Row{i, st.State, port, st.Leased})
+	}
+	t.SetStyle(table.StyleRounded)
+	t.Render()
+
+	return 0
+}
+
+func (c *PoolStatusCommand) Flags() *flag.Sets {
+	return c.flagSet(flagSetDefault, func(sets *flag.Sets) {
+		// Nothing today
+	})
+}
+
+func (c *PoolStatusCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (c *PoolStatusCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *PoolStatusCommand) Synopsis() string {
+	return "Show the state of the clone container pool"
+}
+
+func (c *PoolStatusCommand) Help() string {
+	return formatHelp(`
+Usage: squire pool status [options]
+
+  Show the state of each container in the pool and whether it is leased.
+
+  Containers that aren't created yet are started the next time a clone
+  is leased from the pool.
+
+` + c.Flags().Help())
+}
+
+type PoolDrainCommand struct {
+	*baseCommand
+}
+
+func (c *PoolDrainCommand) Run(args []string) int {
+	ctx := c.Ctx
+
+	if err := c.Init(
+		WithArgs(args),
+		WithFlags(c.Flags(), nil),
+	); err != nil {
+		return c.exitError(err)
+	}
+
+	pool, err := c.Squire.Pool()
+	if err != nil {
+		return c.exitError(err)
+	}
+
+	// We need to capture stdout/stderr because the compose API doesn't
+	// allow configurable output streams.
+	var drained []string
+	err = stdcapture.SuccessOnly(ioutil.Discard, ioutil.Discard, func() error {
+		var err error
+		drained, err = pool.Drain(ctx)
+		return err
+	})
+	if err != nil {
+		return c.exitError(err)
+	}
+
+	if len(drained) == 0 {
+		fmt.Println("No pool containers found.")
+		return 0
+	}
+
+	colorSuccess.Printf("Destroyed %d pool container(s).\n", len(drained))
+	return 0
+}
+
+func (c *PoolDrainCommand) Flags() *flag.Sets {
+	return c.flagSet(flagSetDefault, func(sets *flag.Sets) {
+		// Nothing today
+	})
+}
+
+func (c *PoolDrainCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (c *PoolDrainCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *PoolDrainCommand) Synopsis() string {
+	return "Destroy all containers in the clone container pool"
+}
+
+func (c *PoolDrainCommand) Help() string {
+	return formatHelp(`
+Usage: squire pool drain [options]
+
+  Destroy every container in the pool, including leased containers and
+  containers left over from a larger "dev.pool_size".
+
+  This is safe to run at any time. If the pool is enabled, it is started
+  again the next time a clone is leased.
+
+` + c.Flags().Help())
+}
@@ -13,6 +13,8 @@ import (
 
 type ResetCommand struct {
 	*baseCommand
+
+	full bool
 }
 
 func (c *ResetCommand) Run(args []string) int {
@@ -44,7 +46,9 @@ func (c *ResetCommand) Run(args []string) int {
 	}
 
 	// Reset
-	if err := c.Squire.Reset(ctx, &squire.ResetOptions{}); err != nil {
+	if err := c.Squire.Reset(ctx, &squire.ResetOptions{
+		Incremental: !c.full,
+	}); err != nil {
 		return c.exitError(err)
 	}
 
@@ -54,7 +58,13 @@ func (c *ResetCommand) Run(args []string) int {
 
 func (c *ResetCommand) Flags() *flag.Sets {
 	return c.flagSet(flagSetDefault, func(sets *flag.Sets) {
-		// Nothing today
+		f := sets.NewSet("Command Options")
+		f.BoolVar(&flag.BoolVar{
+			Name:    "full",
+			Target:  &c.full,
+			Default: false,
+			Usage:   "Apply the full schema without using checkpoints.",
+		})
 	})
 }
 
@@ -82,6 +92,16 @@ Usage: squire reset [options]
   in development (not production). In development you're usually less worried
   about clean diff-based deploys so reset is more appropriate.
 
+  Reset is incremental: after applying each "NN-" directory, Squire saves
+  a checkpoint of the database (as another database on the same server).
+  The next reset starts from the last checkpoint whose SQL hasn't changed
+  and only applies the directories after it. Checkpoints that are no longer
+  valid are removed automatically. A directory with a top-level SET
+  statement (such as "SET search_path") is applied together with every
+  directory after it so that the setting still applies to them. Use
+  "-full" to apply the full schema without checkpoints, for example if the
+  server was modified in a way that Squire can't detect.
+
   Reset currently only works against the development database. It is not
   possible to reset the production database. You must do this manually
   without the help of Squire; it is too dangerous of an operation to
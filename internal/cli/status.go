@@ -0,0 +1,181 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/posener/complete"
+
+	"github.com/mitchellh/squire/internal/config"
+	"github.com/mitchellh/squire/internal/dbcontainer"
+	"github.com/mitchellh/squire/internal/pkg/flag"
+	"github.com/mitchellh/squire/internal/squire"
+)
+
+type StatusCommand struct {
+	*baseCommand
+}
+
+func (c *StatusCommand) Run(args []string) int {
+	if err := c.Init(
+		WithArgs(args),
+		WithFlags(c.Flags(), nil),
+		WithAllDatabases(),
+	); err != nil {
+		return c.exitError(err)
+	}
+
+	dbs, err := c.databases()
+	if err != nil {
+		return c.exitError(err)
+	}
+
+	exitCode := 0
+	for i, sq := range dbs {
+		if len(dbs) > 1 {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("==> Database: %s\n", sq.DatabaseName())
+		}
+
+		if err := c.status(sq); err != nil {
+			c.printError(err)
+			exitCode = 1
+		}
+	}
+
+	return exitCode
+}
+
+// status prints the status of a single database.
+func (c *StatusCommand) status(sq *squire.Squire) error {
+	ctx := c.Ctx
+
+	// Production is only checked if a deployment was recorded since
+	// there is nothing to compare it to otherwise.
+	var prodURI string
+	var prodErr error
+	if d, err := sq.LastDeployment(); err != nil {
+		return err
+	} else if d != nil {
+		prodURI, prodErr = c.Config.ProdURL()
+	}
+
+	st, err := sq.Status(ctx, &squire.StatusOptions{
+		ProductionURI: prodURI,
+	})
+	if err != nil {
+		return err
+	}
+	if prodErr != nil {
+		st.DriftErr = prodErr
+	}
+
+	fmt.Println("Dev container:")
+	fmt.Printf("  State:  %s\n", st.Container.State)
+	if st.Container.State != dbcontainer.NotCreated {
+		fmt.Printf("  ID:     %s\n", st.Container.ID)
+	}
+	if st.Container.Port > 0 {
+		fmt.Printf("  Port:   %d\n", st.Container.Port)
+	}
+	switch {
+	case st.Container.State != dbcontainer.Running:
+		fmt.Println("  Schema: unknown, the container isn't running")
+	case st.SchemaErr != nil:
+		fmt.Printf("  Schema: unknown, %s\n", st.SchemaErr)
+	case st.SchemaCurrent:
+		colorSuccess.Println("  Schema: up to date")
+	default:
+		colorErrorDetail.Println(`  Schema: out of date, run "squire reset" to update it`)
+	}
+
+	fmt.Println("\nProduction:")
+	switch {
+	case st.Deployment == nil:
+		fmt.Println("  No deployment recorded on this machine.")
+	case errors.Is(st.DriftErr, config.ErrProdNotFound):
+		fmt.Printf("  Last deployment: %s\n", formatTime(st.Deployment.Time))
+		fmt.Println("  Drift:           unknown, the production URL isn't set")
+	case st.DriftErr != nil:
+		fmt.Printf("  Last deployment: %s\n", formatTime(st.Deployment.Time))
+		fmt.Printf("  Drift:           unknown, %s\n", st.DriftErr)
+	case st.Drifted:
+		fmt.Printf("  Last deployment: %s\n", formatTime(st.Deployment.Time))
+		colorErrorDetail.Println(`  Drift:           changed since the last deployment, see "squire diff -production"`)
+	default:
+		fmt.Printf("  Last deployment: %s\n", formatTime(st.Deployment.Time))
+		colorSuccess.Println("  Drift:           none")
+	}
+
+	fmt.Println("\nDangling clones:")
+	if len(st.Clones) == 0 {
+		fmt.Println("  None.")
+		return nil
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"name", "created"})
+	for _, info := range st.Clones {
+		t.AppendRow(table.Row{info.Name, formatTime(info.Created)})
+	}
+	t.SetStyle(table.StyleRounded)
+	t.Render()
+	fmt.Println(`Clones in use by other Squire commands are listed too. Run "squire gc" to destroy old clones.`)
+
+	return nil
+}
+
+func (c *StatusCommand) Flags() *flag.Sets {
+	return c.flagSet(flagSetDefault, func(sets *flag.Sets) {
+		// Nothing today
+	})
+}
+
+func (c *StatusCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (c *StatusCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *StatusCommand) Synopsis() string {
+	return "Show the status of the dev container, schema, and production"
+}
+
+func (c *StatusCommand) Help() string {
+	return formatHelp(`
+Usage: squire status [options]
+
+  Show the status of the development environment in one place.
+
+  This shows the state of the dev container and whether it was last reset
+  with the current SQL files. Changes made to the dev database other than
+  by "squire reset" aren't detected; use "squire diff" to see those. It
+  also lists the clones that were created for a single command, such as a
+  diff, and are still around. These were either left behind and can be
+  destroyed with "squire gc", or are in use by a Squire command that is
+  still running.
+
+  If you have deployed to production from this machine with
+  "squire deploy -production", this also shows whether the production
+  schema has changed since that deployment, such as by a deployment from
+  another machine or a manual change. This connects to production.
+
+  If the project has multiple databases (see the "databases" configuration),
+  the status of every database is shown unless one is chosen with "-db".
+
+` + c.Flags().Help())
+}
+
+// formatTime formats t for humans, with how long ago it was.
+func formatTime(t time.Time) string {
+	ago := time.Since(t).Round(time.Second)
+	return fmt.Sprintf("%s (%s ago)", t.Local().Format("2006-01-02 15:04:05"), ago)
+}
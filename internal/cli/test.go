@@ -2,39 +2,146 @@ package cli
 
 import (
 	"database/sql"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"reflect"
+	"strings"
 
+	"github.com/cockroachdb/errors"
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/mitchellh/go-wordwrap"
 	"github.com/posener/complete"
 
+	"github.com/mitchellh/squire/internal/dbcontainer"
 	"github.com/mitchellh/squire/internal/pkg/flag"
+	"github.com/mitchellh/squire/internal/pkg/stdcapture"
 	"github.com/mitchellh/squire/internal/squire"
 )
 
 type TestCommand struct {
 	*baseCommand
+
+	keep            bool
+	clean           bool
+	cover           bool
+	shadow          bool
+	updatePlans     bool
+	updateSnapshots bool
+	upgradeFrom     string
 }
 
 func (c *TestCommand) Run(args []string) int {
-	ctx := c.Ctx
-
 	if err := c.Init(
 		WithArgs(args),
 		WithFlags(c.Flags(), nil),
+		WithAllDatabases(),
 	); err != nil {
 		return c.exitError(err)
 	}
 
+	// A kept test database is found again with "squire console -test",
+	// which only knows about the test container.
+	if c.keep && c.shadow {
+		return c.exitError(errors.New("-keep can't be used with -shadow"))
+	}
+
+	dbs, err := c.databases()
+	if err != nil {
+		return c.exitError(err)
+	}
+
+	// Every database is tested even if one fails so that all the
+	// failures are reported at once.
+	exitCode := 0
+	for _, sq := range dbs {
+		if len(dbs) > 1 {
+			fmt.Printf("==> Database: %s\n", sq.DatabaseName())
+		}
+
+		// If we're cleaning, we only destroy the kept test container.
+		run := c.runTests
+		if c.clean {
+			run = c.runClean
+		}
+		if err := run(sq); err != nil {
+			c.printError(err)
+			exitCode = 1
+		}
+	}
+
+	return exitCode
+}
+
+// runTests runs the tests for a single database.
+func (c *TestCommand) runTests(sq *squire.Squire) error {
+	ctx := c.Ctx
+
 	// Run tests
-	if err := c.Squire.TestPGUnit(ctx, &squire.TestPGUnitOptions{
-		Callback: c.renderPGUnitResults,
+	if err := sq.TestPGUnit(ctx, &squire.TestPGUnitOptions{
+		Callback:      c.renderPGUnitResults,
+		Keep:          c.keep,
+		Shadow:        c.shadow,
+		Cover:         c.cover,
+		CoverCallback: c.renderCoverage,
+		PlanCallback:  c.renderPlanResults,
+		UpdatePlans:   c.updatePlans,
+
+		SnapshotCallback: c.renderSnapshotResults,
+		UpdateSnapshots:  c.updateSnapshots,
+
+		UpgradeFrom: c.upgradeFrom,
 	}); err != nil {
-		return c.exitError(err)
+		return err
+	}
+
+	if c.keep {
+		ctr, err := sq.TestContainer(ctx)
+		if err != nil {
+			return err
+		}
+
+		dbFlag := ""
+		if name := sq.DatabaseName(); name != "" {
+			dbFlag = " -db " + name
+		}
+
+		fmt.Printf("\n==> Test database kept running at: %s\n", ctr.ConnURI())
+		fmt.Printf("    Connect with \"squire console -test%[1]s\", destroy with \"squire test -clean%[1]s\".\n", dbFlag)
 	}
 
-	return 0
+	return nil
+}
+
+// runClean destroys the test container kept by a prior "-keep" run.
+func (c *TestCommand) runClean(sq *squire.Squire) error {
+	ctx := c.Ctx
+
+	ctr, err := sq.TestContainer(ctx)
+	if err != nil {
+		return err
+	}
+
+	st, err := ctr.Status(ctx)
+	if err != nil {
+		return err
+	}
+	if st.State == dbcontainer.NotCreated {
+		fmt.Println("No kept test database found.")
+		return nil
+	}
+
+	// We need to capture stdout/stderr because the compose API doesn't
+	// allow configurable output streams.
+	err = stdcapture.SuccessOnly(ioutil.Discard, ioutil.Discard, func() error {
+		return ctr.Down(ctx)
+	})
+	if err != nil {
+		return err
+	}
+
+	colorSuccess.Println("Test database destroyed.")
+	return nil
 }
 
 func (c *TestCommand) renderPGUnitResults(rows *sql.Rows) error {
@@ -100,9 +207,155 @@ func (c *TestCommand) renderPGUnitResults(rows *sql.Rows) error {
 	return nil
 }
 
+func (c *TestCommand) renderCoverage(cover []*squire.FunctionCoverage) error {
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"function", "file", "calls", "total time"})
+
+	var missed []*squire.FunctionCoverage
+	for _, fc := range cover {
+		if fc.Calls == 0 {
+			missed = append(missed, fc)
+		}
+
+		t.AppendRow(table.Row{fc.Signature, fc.File, fc.Calls, fc.TotalTime})
+	}
+
+	fmt.Println("\n==> Function coverage")
+	t.SetStyle(table.StyleRounded)
+	t.Render()
+
+	if len(missed) > 0 {
+		fmt.Printf("\n==> Functions never called (%d)\n", len(missed))
+		for _, fc := range missed {
+			file := fc.File
+			if file == "" {
+				file = "unknown file"
+			}
+
+			fmt.Printf("    %s (%s)\n", fc.Signature, file)
+		}
+	}
+
+	// Output our final percentage
+	percent := 100.0
+	if len(cover) > 0 {
+		percent = 100 * float64(len(cover)-len(missed)) / float64(len(cover))
+	}
+	fmt.Printf("\nCoverage: %d of %d functions called (%.1f%%)\n",
+		len(cover)-len(missed), len(cover), percent)
+
+	return nil
+}
+
+func (c *TestCommand) renderPlanResults(results []*squire.GoldenResult) error {
+	return c.renderGoldenResults("Query plan tests", results, errDetailPlanFailed)
+}
+
+func (c *TestCommand) renderSnapshotResults(results []*squire.GoldenResult) error {
+	return c.renderGoldenResults("Query snapshot tests", results, errDetailSnapshotFailed)
+}
+
+// renderGoldenResults renders the results of tests that compare to golden
+// files. If any failed, an error is returned with the diffs in the details.
+func (c *TestCommand) renderGoldenResults(
+	title string,
+	results []*squire.GoldenResult,
+	detailFmt string,
+) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	fmt.Printf("\n==> %s\n", title)
+	var failed []*squire.GoldenResult
+	for _, r := range results {
+		switch {
+		case r.Updated:
+			fmt.Printf("    UPDATED %s\n", r.File)
+		case r.Passed:
+			colorSuccess.Printf("    PASS    %s\n", r.File)
+		default:
+			colorError.Printf("    FAIL    %s\n", r.File)
+			failed = append(failed, r)
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	var detail strings.Builder
+	for _, r := range failed {
+		fmt.Fprintf(&detail, "%s\n\n", strings.TrimSpace(r.Diff))
+	}
+
+	return errors.WithDetailf(
+		errors.Newf("%d of %d %s failed", len(failed), len(results), strings.ToLower(title)),
+		strings.TrimSpace(detailFmt),
+		strings.TrimSpace(detail.String()),
+	)
+}
+
 func (c *TestCommand) Flags() *flag.Sets {
 	return c.flagSet(flagSetDefault, func(sets *flag.Sets) {
-		// Nothing today
+		f := sets.NewSet("Command Options")
+
+		f.BoolVar(&flag.BoolVar{
+			Name:    "keep",
+			Target:  &c.keep,
+			Default: false,
+			Usage: "Do not destroy the test database after running tests. " +
+				"Use \"squire console -test\" to connect to it.",
+		})
+
+		f.BoolVar(&flag.BoolVar{
+			Name:    "clean",
+			Target:  &c.clean,
+			Default: false,
+			Usage:   "Destroy a test database kept with -keep and do not run tests.",
+		})
+
+		f.BoolVar(&flag.BoolVar{
+			Name:    "shadow",
+			Target:  &c.shadow,
+			Default: false,
+			Usage: "Run the tests in a shadow database within the running dev " +
+				"container instead of starting a test container.",
+		})
+
+		f.BoolVar(&flag.BoolVar{
+			Name:    "cover",
+			Target:  &c.cover,
+			Default: false,
+			Usage: "Report which functions and procedures in the schema are " +
+				"called by the tests.",
+		})
+
+		f.BoolVar(&flag.BoolVar{
+			Name:    "update-plans",
+			Target:  &c.updatePlans,
+			Default: false,
+			Usage: "Write the query plans from files ending in _plan.sql to " +
+				"their golden files rather than comparing them.",
+		})
+
+		f.BoolVar(&flag.BoolVar{
+			Name:    "update-snapshots",
+			Target:  &c.updateSnapshots,
+			Default: false,
+			Usage: "Write the query results from files ending in _snapshot.sql " +
+				"to their golden files rather than comparing them.",
+		})
+
+		f.StringVar(&flag.StringVar{
+			Name:    "upgrade-from",
+			Target:  &c.upgradeFrom,
+			Default: "",
+			Usage: "Test the upgrade path from a previous schema. This can be " +
+				"a git ref or a path to a SQL file such as a schema dump. " +
+				"Requires pgquarrel.",
+		})
 	})
 }
 
@@ -134,10 +387,110 @@ Usage: squire test [options]
   into the "pgunit" schema so you must prefix all pgUnit function calls with
   "pgunit.".
 
-  The test database is always destroyed at the end of the command. If you
-  want to debug tests, run "squire reset -include-tests" to reset your
-  development database with the test schema. Then you can use "squire console"
-  or any other means to debug your database.
+  Squire also installs its own assertions into the "squire_test" schema.
+  These raise the same errors as pgUnit assertions, so failures are reported
+  the same way. Failure messages show a diff of the values where possible.
+
+    squire_test.assert_equals([message,] expected, actual)
+    squire_test.assert_not_equals([message,] unexpected, actual)
+    squire_test.assert_raises(query [, sqlstate])
+    squire_test.assert_rows_equal(expected_query, actual_query)
+    squire_test.assert_has_index(table, index_name | columns[])
+    squire_test.assert_has_column(table, column [, type])
+
+  Functions can be mocked with "squire_test.mock_function(signature, body
+  [, language])", i.e. to replace a function that depends on the current
+  time. The body language defaults to "sql". The function keeps its other
+  attributes, such as SECURITY DEFINER and SET search_path. Since every
+  test runs in a transaction that is rolled back, mocks are restored
+  automatically after each test. Use "squire_test.restore_function(signature)" to restore a
+  function before the test ends.
+
+  To test row-level security policies, a test case can run as another role
+  by adding a "-- squire:test-role <role> [setting=value ...]" comment
+  directly above its CREATE FUNCTION statement. The settings are set for
+  the test, i.e. "request.jwt.claim.sub=42". Setup and teardown still run
+  as the original role. Within a test, "squire_test.set_role(role [, settings])"
+  and "squire_test.reset_role()" switch roles explicitly, where settings is
+  a JSON object. Roles are created in the test database before the schema
+  is applied, either from the "test.roles" configuration or from files
+  ending in "_roles.sql". Roles that already exist are ignored.
+
+  Every test case runs in its own transaction that is rolled back when the
+  test completes, so tests can't leak data into each other regardless of the
+  order they run in. pgUnit setup and teardown functions run inside this
+  transaction as well. Files ending in "_fixture.sql" are loaded once after
+  the schema and are visible to every test. Files ending in "_setup.sql" are
+  run before every test case defined in the same "NN-" directory.
+
+  The test database is destroyed at the end of the command by default. If
+  you want to debug tests, specify the "-keep" flag to leave the test database
+  running with the schema and pgUnit installed. The connection URL is printed
+  at the end of the run. You can use "squire console -test" or any other means
+  to debug the database. When you're done, run "squire test -clean" to
+  destroy it. Running "squire test" again reuses a kept test database.
+
+  If the project has multiple databases (see the "databases" configuration),
+  the tests for every database are run unless one is chosen with "-db".
+
+  The "-shadow" flag runs the tests in a new shadow database named
+  "squire_shadow_test_<time>" within the running dev container instead of
+  starting a separate test container. This is much faster, but any test
+  roles are also created in the dev container. The dev database itself is
+  not modified. The shadow database is dropped when the tests complete.
+  This can't be used with "-keep".
+
+  The "-cover" flag reports the number of times each function and procedure
+  in your schema was called during the test run, along with the SQL file
+  that defines it. Functions that were never called are listed separately.
+  This uses PostgreSQL's "track_functions" statistics, so functions that
+  PostgreSQL inlines (some simple SQL-language functions) may be reported
+  as never called.
+
+  Files ending in "_plan.sql" are query plan tests. Each query in these files
+  is run with "EXPLAIN (COSTS OFF)" against the test database and the output
+  is compared to the golden file next to it (i.e. "foo_plan.golden" for
+  "foo_plan.sql"). This can catch queries that stop using an index due to
+  schema changes. Plan files can also contain "SET" statements to change
+  planner settings for the queries that follow. Run with "-update-plans" to
+  create or update the golden files, and check them into version control.
+
+  Files ending in "_snapshot.sql" are query result snapshot tests. Every
+  statement in these files is run in a single transaction that is rolled
+  back at the end, so they may insert their own data. The results of every
+  statement that returns rows are compared to the golden file next to it
+  (i.e. "foo_snapshot.csv" for "foo_snapshot.sql"). The golden file format
+  is CSV by default and can be changed to JSON with the "test.snapshot_format"
+  configuration. Queries should use "ORDER BY" so the results are stable.
+  Run with "-update-snapshots" to create or update the golden files.
+
+  The "-upgrade-from" flag tests the upgrade path from a previous version
+  of the schema instead of a fresh schema. The value can be a git ref (the
+  SQL directory is read from the repository at that ref) or a path to a SQL
+  file, such as a schema dump of production. The previous schema is applied to
+  the test database, the diff to the current schema is generated and applied
+  just like "squire deploy", and then the tests are run on the upgraded
+  database. This verifies that deploying by diff results in a working schema.
 
 ` + c.Flags().Help())
 }
+
+const (
+	errDetailPlanFailed = `
+The query plans for one or more queries in the "_plan.sql" files did not
+match the expected plans in the golden files. The differences are shown below.
+If the new plans are expected, run "squire test -update-plans" to update the
+golden files.
+
+%s
+`
+
+	errDetailSnapshotFailed = `
+The query results for one or more queries in the "_snapshot.sql" files did
+not match the expected results in the golden files. The differences are shown
+below. If the new results are expected, run "squire test -update-snapshots"
+to update the golden files.
+
+%s
+`
+)
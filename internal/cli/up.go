@@ -16,19 +16,27 @@ func (c *UpCommand) Run(args []string) int {
 	if err := c.Init(
 		WithArgs(args),
 		WithFlags(c.Flags(), nil),
+		WithAllDatabases(),
 	); err != nil {
 		return c.exitError(err)
 	}
 
-	// Get our container
-	ctr, err := c.Squire.Container()
+	dbs, err := c.databases()
 	if err != nil {
 		return c.exitError(err)
 	}
 
-	// Launch it
-	if err := ctr.Up(ctx); err != nil {
-		return c.exitError(err)
+	for _, sq := range dbs {
+		// Get our container
+		ctr, err := sq.Container()
+		if err != nil {
+			return c.exitError(err)
+		}
+
+		// Launch it
+		if err := ctr.Up(ctx); err != nil {
+			return c.exitError(err)
+		}
 	}
 
 	return 0
@@ -62,11 +70,23 @@ Usage: squire up [options]
   for development. Under the hood, this uses Docker Compose, and you may
   utilize an existing Compose configuration file if it exists.
 
-  If you have a "docker-compose.yml" file in this or any parent
-  directories, then Squire will attempt to find a database service by
-  looking for a service with the "x-squire" configuration set. If no
-  database service is found, Squire will spin up a default PostgreSQL
-  container.
+  If you have a Docker Compose file (such as "compose.yaml" or
+  "docker-compose.yml") in this or any parent directories, then Squire will
+  attempt to find a database service by looking for a service with the
+  "x-squire" configuration set. If no database service is found, Squire
+  will spin up a default PostgreSQL container.
+
+  With "dev.backend" set to "local", Squire doesn't use Docker. Instead,
+  it creates and starts a PostgreSQL cluster using the initdb and pg_ctl
+  programs of a locally installed PostgreSQL. Clones for tests and diffs
+  are separate clusters on their own ports.
+
+  With "dev.backend" set to "external", Squire creates the development
+  database on an existing PostgreSQL server, such as a service container
+  in CI, and clones are other databases on that server.
+
+  If the project has multiple databases (see the "databases" configuration),
+  every database is started unless one is chosen with "-db".
 
   You can destroy the running development database using "squire down".
   If you want to just reapply the schema, you can run "squire reset".
@@ -0,0 +1,168 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/posener/complete"
+
+	"github.com/mitchellh/squire/internal/dbcontainer"
+	"github.com/mitchellh/squire/internal/pkg/flag"
+	"github.com/mitchellh/squire/internal/squire"
+)
+
+type WatchCommand struct {
+	*baseCommand
+
+	test     bool
+	shadow   bool
+	debounce time.Duration
+}
+
+func (c *WatchCommand) Run(args []string) int {
+	ctx := c.Ctx
+
+	if err := c.Init(
+		WithArgs(args),
+		WithFlags(c.Flags(), nil),
+	); err != nil {
+		return c.exitError(err)
+	}
+
+	// Verify our container is running
+	ctr, err := c.Squire.Container()
+	if err != nil {
+		return c.exitError(err)
+	}
+
+	st, err := ctr.Status(ctx)
+	if err != nil {
+		return c.exitError(err)
+	}
+
+	if st.State != dbcontainer.Running {
+		return c.exitError(errors.WithDetail(
+			errors.New("database container is not running"),
+			strings.TrimSpace(errDetailNotRunning),
+		))
+	}
+
+	fmt.Printf("==> Watching %q for changes. Press Ctrl-C to stop.\n", c.Config.SQLDir)
+	if err := c.Squire.Watch(ctx, &squire.WatchOptions{
+		Container:     ctr,
+		Test:          c.test,
+		Shadow:        c.shadow,
+		Debounce:      c.debounce,
+		StartCallback: c.renderStart,
+		Callback:      c.renderResult,
+	}); err != nil {
+		return c.exitError(err)
+	}
+
+	return 0
+}
+
+func (c *WatchCommand) renderStart(files []string) {
+	if len(files) == 0 {
+		return
+	}
+
+	fmt.Printf("\n==> Changed: %s\n", strings.Join(files, ", "))
+}
+
+func (c *WatchCommand) renderResult(r *squire.WatchResult) {
+	ts := time.Now().Format("15:04:05")
+	dur := r.Duration.Round(10 * time.Millisecond)
+
+	switch {
+	case r.ResetErr != nil:
+		colorError.Printf("%s ✗ reset failed (%s)\n", ts, dur)
+		c.printError(r.ResetErr)
+
+	case r.TestErr != nil:
+		colorError.Printf("%s ✗ reset ok, tests could not run (%s)\n", ts, dur)
+		c.printError(r.TestErr)
+
+	case !r.Tested:
+		colorSuccess.Printf("%s ✓ reset ok (%s)\n", ts, dur)
+
+	case r.Failed > 0:
+		colorError.Printf("%s ✗ reset ok, %d passed, %d failed (%s)\n",
+			ts, r.Passed, r.Failed, dur)
+		for _, f := range r.Failures {
+			colorErrorDetail.Printf("    %s\n", f)
+		}
+
+	default:
+		colorSuccess.Printf("%s ✓ reset ok, %d passed (%s)\n", ts, r.Passed, dur)
+	}
+}
+
+func (c *WatchCommand) Flags() *flag.Sets {
+	return c.flagSet(flagSetDefault, func(sets *flag.Sets) {
+		f := sets.NewSet("Command Options")
+		f.BoolVar(&flag.BoolVar{
+			Name:    "test",
+			Target:  &c.test,
+			Default: false,
+			Usage:   "Run the tests after every successful reset.",
+		})
+
+		f.BoolVar(&flag.BoolVar{
+			Name:    "shadow",
+			Target:  &c.shadow,
+			Default: false,
+			Usage: "With -test, run the tests in a shadow database within the " +
+				"dev container instead of a separate test container.",
+		})
+
+		f.DurationVar(&flag.DurationVar{
+			Name:    "debounce",
+			Target:  &c.debounce,
+			Default: 250 * time.Millisecond,
+			Usage: "Time to wait after a change before resetting. Additional " +
+				"changes during this time restart the wait.",
+		})
+	})
+}
+
+func (c *WatchCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (c *WatchCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *WatchCommand) Synopsis() string {
+	return "Reset (and test) the dev database when SQL files change"
+}
+
+func (c *WatchCommand) Help() string {
+	return formatHelp(`
+Usage: squire watch [options]
+
+  Watch the SQL directory and reset the dev database on every change.
+
+  This automates the common workflow of editing SQL files and running
+  "squire reset". The database is reset once when the command starts and
+  again every time a SQL file changes. Changes made in quick succession
+  (such as saving many files at once) result in a single reset.
+
+  With "-test", the tests are also run after every successful reset, just
+  like "squire test". The test database is kept running between runs so
+  they're fast, and is destroyed when the command exits. Add "-shadow" to
+  run the tests in a shadow database within the dev container instead,
+  which avoids starting a test container at all (see "squire test -h").
+
+  A status line is shown after each reset. Errors building or applying the
+  schema and failed tests are shown in place and the command continues
+  watching. Press Ctrl-C to stop.
+
+  Just like "squire reset", this destroys all data in the dev database
+  and requires the dev database to be running ("squire up").
+
+` + c.Flags().Help())
+}
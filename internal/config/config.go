@@ -20,8 +20,33 @@ type Config struct {
 
 	SQLDir string `json:"sql_dir"`
 
+	Databases map[string]Database `json:"databases"`
+
 	Dev struct {
-		DefaultImage string `json:"default_image"`
+		Backend string
+		Local   struct {
+			BinDir  string `json:"bin_dir"`
+			DataDir string `json:"data_dir"`
+		}
+		External struct {
+			URL    string `json:"url"`
+			URLEnv string `json:"url_env"`
+			Prefix string
+		}
+		DefaultImage string            `json:"default_image"`
+		Port         int               `json:"port"`
+		DB           string            `json:"db"`
+		Env          map[string]string `json:"env"`
+		Settings     map[string]string `json:"settings"`
+		Volumes      []string          `json:"volumes"`
+		PoolSize     int               `json:"pool_size"`
+		FastClones   *bool             `json:"fast_clones"`
+	}
+
+	Test struct {
+		Mode           string
+		SnapshotFormat string `json:"snapshot_format"`
+		Roles          []string
 	}
 
 	Production struct {
@@ -31,6 +56,13 @@ type Config struct {
 	}
 }
 
+// Database is the configuration for a single named database. See
+// Config.Databases.
+type Database struct {
+	Service string `json:"service"`
+	SQLDir  string `json:"sql_dir"`
+}
+
 // ProdURL returns the URL to the production database. This will never
 // return an empty string with a nil error. This will return an error if the
 // production URL could not be determined. An empty string error will be
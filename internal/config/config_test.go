@@ -17,7 +17,11 @@ func TestLoad_empty(t *testing.T) {
 
 	// We should have a default
 	require.NotEmpty(cfg.Dev.DefaultImage)
+	require.Zero(cfg.Dev.PoolSize)
+	require.Nil(cfg.Dev.FastClones)
 	require.Equal("PGURI", cfg.Production.Env)
+	require.Equal("csv", cfg.Test.SnapshotFormat)
+	require.Empty(cfg.Test.Roles)
 }
 
 func TestLoad_file(t *testing.T) {
@@ -78,3 +82,90 @@ func TestLoad_prodExec(t *testing.T) {
 	require.NoError(err)
 	require.Equal("foo bar", url)
 }
+
+func TestLoad_testRoles(t *testing.T) {
+	require := require.New(t)
+
+	cfg, err := New(FromString(`test: roles: ["anon", "authenticated"]`))
+	require.NoError(err)
+	require.Equal([]string{"anon", "authenticated"}, cfg.Test.Roles)
+}
+
+func TestLoad_poolSize(t *testing.T) {
+	require := require.New(t)
+
+	cfg, err := New(FromString(`dev: pool_size: 2`))
+	require.NoError(err)
+	require.Equal(2, cfg.Dev.PoolSize)
+
+	_, err = New(FromString(`dev: pool_size: -1`))
+	require.Error(err)
+}
+
+func TestLoad_devContainer(t *testing.T) {
+	require := require.New(t)
+
+	cfg, err := New(FromString(`dev: {
+	port: 15432
+	env: POSTGRES_INITDB_ARGS: "--data-checksums"
+	settings: shared_buffers: "256MB"
+	volumes: ["./init:/docker-entrypoint-initdb.d"]
+}`))
+	require.NoError(err)
+	require.Equal(15432, cfg.Dev.Port)
+	require.Equal(map[string]string{
+		"POSTGRES_INITDB_ARGS": "--data-checksums",
+	}, cfg.Dev.Env)
+	require.Equal(map[string]string{"shared_buffers": "256MB"}, cfg.Dev.Settings)
+	require.Equal([]string{"./init:/docker-entrypoint-initdb.d"}, cfg.Dev.Volumes)
+}
+
+func TestLoad_databases(t *testing.T) {
+	require := require.New(t)
+
+	cfg, err := New(FromString(`
+sql_dir: "db"
+databases: {
+	oltp: {}
+	reporting: {
+		service: "warehouse"
+		sql_dir: "reports"
+	}
+}
+`))
+	require.NoError(err)
+	require.Equal(map[string]Database{
+		"oltp":      {Service: "oltp", SQLDir: "db/oltp"},
+		"reporting": {Service: "warehouse", SQLDir: "reports"},
+	}, cfg.Databases)
+
+	// No databases by default
+	cfg, err = New()
+	require.NoError(err)
+	require.Empty(cfg.Databases)
+}
+
+func TestLoad_backend(t *testing.T) {
+	require := require.New(t)
+
+	cfg, err := New()
+	require.NoError(err)
+	require.Equal("docker", cfg.Dev.Backend)
+
+	cfg, err = New(FromString(`dev: {
+	backend: "local"
+	local: bin_dir: "/usr/lib/postgresql/13/bin"
+}`))
+	require.NoError(err)
+	require.Equal("local", cfg.Dev.Backend)
+	require.Equal("/usr/lib/postgresql/13/bin", cfg.Dev.Local.BinDir)
+
+	cfg, err = New(FromString(`dev: backend: "external"`))
+	require.NoError(err)
+	require.Equal("external", cfg.Dev.Backend)
+	require.Equal("SQUIRE_EXTERNAL_URL", cfg.Dev.External.URLEnv)
+	require.Equal("squire_", cfg.Dev.External.Prefix)
+
+	_, err = New(FromString(`dev: backend: "podman"`))
+	require.Error(err)
+}
@@ -2,7 +2,6 @@ package dbcompose
 
 import (
 	"fmt"
-	"os"
 	"strings"
 
 	"github.com/cockroachdb/errors"
@@ -14,12 +13,25 @@ import (
 // Config is the primary configuration structure. This should not be constructed
 // directly. Instead, you should use the constructor methods.
 type Config struct {
-	logger  hclog.Logger
-	project *types.Project
+	logger      hclog.Logger
+	project     *types.Project
+	fastClones  bool
+	serviceName string
+
+	// name is the name of the database service, see Name.
+	name string
 
 	// populated by init
-	service *types.ServiceConfig
-	connURI string
+	service    *types.ServiceConfig
+	connURI    string
+	targetPort uint32
+
+	// database, if set, overrides the database name in connURI. See Database.
+	database string
+
+	// host, if set, is the host in connURI if the extension doesn't
+	// specify one. See SetHost.
+	host string
 }
 
 // New initializes a new container configuration. This will load and validate
@@ -33,43 +45,52 @@ func New(opts ...Option) (*Config, error) {
 	optsVal.SetDefaults()
 
 	// Build our config
-	cfg := &Config{logger: optsVal.Logger}
+	cfg := &Config{
+		logger:      optsVal.Logger,
+		serviceName: optsVal.Service,
+	}
 	L := cfg.logger
 
-	// Try to find a docker compose file
-	if len(optsVal.Paths) > 0 {
-		L.Info("looking for compose file", "paths", optsVal.Paths)
-		for _, p := range optsVal.Paths {
-			LL := L.With("path", p)
-			_, err := os.Stat(p)
-			if os.IsNotExist(err) {
-				continue
-			}
-			if err != nil {
-				LL.Error("error reading compose file", "err", err)
-				return nil, err
-			}
-
-			// This compose file should work, so we read it.
-			cfg.project, err = loadFromFile(p)
-			if err != nil {
-				return nil, err
-			}
+	// Try to find the docker compose files
+	paths, profiles, err := optsVal.files()
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) > 0 {
+		L.Info("loading compose files", "paths", paths, "profiles", profiles)
+		cfg.project, err = loadFromFiles(paths, profiles)
+		if err != nil {
+			return nil, err
 		}
+	}
 
-		// If our project is still nil, and we have no default, then we error.
-		if cfg.project == nil && optsVal.Default == nil {
+	// If our project is still nil, and we have no default, then we error.
+	if cfg.project == nil && optsVal.Default == nil {
+		if len(optsVal.Paths) > 0 {
 			return nil, errors.WithDetailf(
 				errors.Newf("failed to find a Docker Compose file: %v", optsVal.Paths),
 				strings.TrimSpace(errDetailNoFile),
 				optsVal.Paths,
 			)
 		}
+		if optsVal.Discover {
+			return nil, errors.WithDetail(
+				errors.New("failed to find a Docker Compose file"),
+				strings.TrimSpace(errDetailNoDiscover),
+			)
+		}
 	}
 
 	// If our project is nil we set a default
 	if cfg.project == nil {
-		cfg.project = optsVal.Default
+		cfg.project, err = optsVal.Default()
+		if err != nil {
+			return nil, err
+		}
+		cfg.fastClones = true
+	}
+	if optsVal.FastClones != nil {
+		cfg.fastClones = *optsVal.FastClones
 	}
 
 	// If our project is STILL nil then its an error. It shouldn't be
@@ -78,6 +99,13 @@ func New(opts ...Option) (*Config, error) {
 		panic("nil project, no default?")
 	}
 
+	// The name includes the service if it was chosen, since then there
+	// may be more than one database service in the project.
+	cfg.name = cfg.project.Name
+	if cfg.serviceName != "" {
+		cfg.name += "-" + cfg.serviceName
+	}
+
 	// Initialize, validate
 	if err := cfg.init(); err != nil {
 		return nil, err
@@ -91,6 +119,19 @@ func (c *Config) Project() *types.Project {
 	return c.project
 }
 
+// Name returns a name for the database service that is unique across
+// projects. This is the project name, followed by the service name if the
+// service was chosen with WithService. Clones are named after this.
+func (c *Config) Name() string {
+	return c.name
+}
+
+// Files returns the Compose files the project was loaded from, in the order
+// they were merged. This is empty if the default project is used.
+func (c *Config) Files() []string {
+	return c.project.ComposeFiles
+}
+
 // Service returns the service name that is being used.
 func (c *Config) Service() string {
 	return c.service.Name
@@ -102,12 +143,62 @@ func (c *Config) ConnURI() string {
 	return c.connURI
 }
 
+// TargetPort is the port that PostgreSQL is listening on within the
+// container. This is not the port on the host, see ConnURI for that.
+func (c *Config) TargetPort() uint32 {
+	return c.targetPort
+}
+
+// SetLabel sets a label on the database service. This modifies the
+// config in-place.
+func (c *Config) SetLabel(k, v string) {
+	c.service.Labels[k] = v
+}
+
+// SetHost sets the host to connect to if the "x-squire" extension doesn't
+// set one. This is usually the host of the Docker engine, since published
+// ports are only reachable there. This modifies the config in-place.
+func (c *Config) SetHost(v string) error {
+	c.host = v
+	return c.init()
+}
+
+// SetPort replaces the host port that the database service is published
+// on and updates the connection information. This modifies the config
+// in-place. This is used to reattach to a clone created by a previous
+// process, since clones are created with a random port.
+func (c *Config) SetPort(v uint32) error {
+	if err := pgReplacePort(c.service, v); err != nil {
+		return err
+	}
+
+	return c.init()
+}
+
+// Database returns a copy of the config that connects to a different
+// logical database on the same database service. The project is shared
+// with the original config, so any lifecycle operations (such as bringing
+// the project down) affect the original service as well. The database
+// itself is not created.
+func (c *Config) Database(name string) (*Config, error) {
+	c2 := *c
+	c2.database = name
+	if err := c2.init(); err != nil {
+		return nil, err
+	}
+
+	return &c2, nil
+}
+
 // Clone creates a "clone" database service. This clone is of the container
 // settings and does NOT contain any of the data of the container. The expected
 // use case of this is to spin up alternate instances of a database.
 //
 // The clone has to be given a unique name.
 //
+// If the config was created with WithFastClones, the clone runs PostgreSQL
+// without durability since the data is expected to be thrown away.
+//
 // The clone is not persisted to the original compose configuration so if
 // the user runs docker-compose down or something outside of Squire then
 // it could bring that database down.
@@ -124,7 +215,18 @@ func (c *Config) Clone(n string) (*Config, error) {
 
 	// Rename our project so that Down works properly and so that its
 	// idempotent with regards to this clone name.
-	p.Name = fmt.Sprintf("%s-%s", p.Name, n)
+	p.Name = fmt.Sprintf("%s-%s", c.name, n)
+
+	// Named volumes are renamed for the clone so that the clone never
+	// shares data with the original.
+	for k, v := range p.Volumes {
+		if v.External.External {
+			continue
+		}
+
+		v.Name = fmt.Sprintf("%s_%s", p.Name, k)
+		p.Volumes[k] = v
+	}
 
 	// Modify our project service. What we want to do here is create a new
 	// service with a new name that is otherwise identical to our current service.
@@ -144,6 +246,11 @@ func (c *Config) Clone(n string) (*Config, error) {
 	}
 	svc.Ports = []types.ServicePortConfig{*portConfig}
 
+	// Clones are throwaway so they don't need durability.
+	if c.fastClones {
+		pgFast(svc)
+	}
+
 	// We need to replace the networks with a new network so we can
 	// bring this one down on its own.
 	svc.Networks = map[string]*types.ServiceNetworkConfig{
@@ -161,11 +268,16 @@ func (c *Config) Clone(n string) (*Config, error) {
 	// Shallow copy ourselves.
 	c2 := *c
 	c2.project = p
+	c2.name = p.Name
+	if c2.serviceName != "" {
+		c2.serviceName = svc.Name
+	}
 
 	// Reinitialize
 	if err := c2.init(); err != nil {
 		return nil, err
 	}
+	c2.SetLabel(LabelClone, c.name)
 
 	return &c2, nil
 }
@@ -0,0 +1,173 @@
+package dbcompose
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	composecli "github.com/compose-spec/compose-go/cli"
+)
+
+// Discover finds the Compose files for the project in dir (the current
+// working directory if empty) following the Compose specification.
+//
+// If COMPOSE_FILE is set, it is a list of files separated by
+// COMPOSE_PATH_SEPARATOR (or the OS path list separator) and every file
+// must exist. Otherwise, dir and then each parent directory is searched for
+// compose.yaml, compose.yml, docker-compose.yaml, or docker-compose.yml
+// (in that order). The first file found is used along with an override
+// file (i.e. compose.override.yaml) in the same directory, if any.
+//
+// Environment variables are read from the OS environment and from a ".env"
+// file in dir, with the OS environment taking precedence. The files are
+// returned as absolute paths in the order they should be merged. If no
+// files are found, this returns nil with no error.
+func Discover(dir string) ([]string, error) {
+	env, err := discoverEnv(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return discoverFiles(dir, env)
+}
+
+// discoverEnv returns the environment used for discovery. See Discover.
+func discoverEnv(dir string) (map[string]string, error) {
+	opts, err := composecli.NewProjectOptions(nil,
+		composecli.WithWorkingDirectory(dir),
+		composecli.WithDotEnv,
+		composecli.WithOsEnv,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return opts.Environment, nil
+}
+
+// discoverFiles finds the Compose files using the given environment. See
+// Discover.
+func discoverFiles(dir string, env map[string]string) ([]string, error) {
+	if dir == "" {
+		var err error
+		dir, err = os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// COMPOSE_FILE takes precedence over any search.
+	if v := env[envComposeFile]; v != "" {
+		sep := env[envComposePathSeparator]
+		if sep == "" {
+			sep = string(os.PathListSeparator)
+		}
+
+		var result []string
+		for _, p := range strings.Split(v, sep) {
+			if p == "" {
+				continue
+			}
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(dir, p)
+			}
+			if _, err := os.Stat(p); err != nil {
+				return nil, errors.WithDetailf(
+					errors.Newf("failed to read Compose file from %s: %w", envComposeFile, err),
+					strings.TrimSpace(errDetailComposeFile),
+					v,
+				)
+			}
+
+			result = append(result, p)
+		}
+
+		return result, nil
+	}
+
+	for {
+		p, err := findFirst(dir, defaultFileNames)
+		if err != nil {
+			return nil, err
+		}
+		if p != "" {
+			result := []string{p}
+			override, err := findFirst(dir, defaultOverrideFileNames)
+			if err != nil {
+				return nil, err
+			}
+			if override != "" {
+				result = append(result, override)
+			}
+
+			return result, nil
+		}
+
+		next := filepath.Dir(dir)
+		if next == dir {
+			return nil, nil
+		}
+
+		dir = next
+	}
+}
+
+// discoverProfiles returns the Compose profiles enabled by COMPOSE_PROFILES.
+func discoverProfiles(env map[string]string) []string {
+	var result []string
+	for _, p := range strings.Split(env[envComposeProfiles], ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			result = append(result, p)
+		}
+	}
+
+	return result
+}
+
+// findFirst returns the path to the first file in names that exists in dir,
+// or an empty string if none exist.
+func findFirst(dir string, names []string) (string, error) {
+	for _, n := range names {
+		p := filepath.Join(dir, n)
+		_, err := os.Stat(p)
+		if err == nil {
+			return p, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+
+	return "", nil
+}
+
+var (
+	// defaultFileNames are the Compose file names searched for, in order
+	// of preference.
+	defaultFileNames = []string{
+		"compose.yaml",
+		"compose.yml",
+		"docker-compose.yaml",
+		"docker-compose.yml",
+	}
+
+	// defaultOverrideFileNames are the Compose override file names searched
+	// for alongside the Compose file, in order of preference.
+	defaultOverrideFileNames = []string{
+		"compose.override.yaml",
+		"compose.override.yml",
+		"docker-compose.override.yaml",
+		"docker-compose.override.yml",
+	}
+)
+
+const (
+	envComposeFile          = composecli.ComposeFilePath
+	envComposePathSeparator = composecli.ComposePathSeparator
+	envComposeProfiles      = "COMPOSE_PROFILES"
+)
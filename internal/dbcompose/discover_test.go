@@ -0,0 +1,85 @@
+package dbcompose
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscover(t *testing.T) {
+	require := require.New(t)
+	t.Setenv(envComposeFile, "")
+
+	dir, err := filepath.Abs("testdata/discover")
+	require.NoError(err)
+
+	// Finds the file and override in the directory
+	paths, err := Discover(dir)
+	require.NoError(err)
+	require.Equal([]string{
+		filepath.Join(dir, "compose.yaml"),
+		filepath.Join(dir, "compose.override.yaml"),
+	}, paths)
+
+	// Finds the same files from a subdirectory
+	paths2, err := Discover(filepath.Join(dir, "nested"))
+	require.NoError(err)
+	require.Equal(paths, paths2)
+}
+
+func TestDiscover_composeFile(t *testing.T) {
+	require := require.New(t)
+	t.Setenv(envComposeFile, "compose-v2.yml,discover/compose.override.yaml")
+	t.Setenv(envComposePathSeparator, ",")
+
+	dir, err := filepath.Abs("testdata")
+	require.NoError(err)
+
+	paths, err := Discover(dir)
+	require.NoError(err)
+	require.Equal([]string{
+		filepath.Join(dir, "compose-v2.yml"),
+		filepath.Join(dir, "discover", "compose.override.yaml"),
+	}, paths)
+
+	// Every file must exist
+	t.Setenv(envComposeFile, "compose-v2.yml,nope.yml")
+	_, err = Discover(dir)
+	require.Error(err)
+	require.Contains(err.Error(), envComposeFile)
+}
+
+func TestNew_discoverMerge(t *testing.T) {
+	require := require.New(t)
+	t.Setenv(envComposeFile, "")
+
+	cfg, err := New(WithDiscover("testdata/discover/nested"))
+	require.NoError(err)
+	require.Len(cfg.Files(), 2)
+
+	// The override is merged over the base file
+	require.Equal("postgres://postgres@localhost:1234/app-override", cfg.ConnURI())
+}
+
+func TestNew_profiles(t *testing.T) {
+	require := require.New(t)
+
+	cfg, err := New(
+		WithPath("testdata/compose-profiles.yml"),
+		WithProfiles("pg14"),
+	)
+	require.NoError(err)
+	require.Equal("db14", cfg.Service())
+
+	// COMPOSE_PROFILES is used when discovering
+	t.Setenv(envComposeFile, "compose-profiles.yml")
+	t.Setenv(envComposeProfiles, "pg13")
+	cfg, err = New(WithDiscover("testdata"))
+	require.NoError(err)
+	require.Equal("db", cfg.Service())
+
+	// No profiles means no service
+	_, err = New(WithPath("testdata/compose-profiles.yml"))
+	require.Error(err)
+}
@@ -8,6 +8,24 @@ check your settings and also ensure the Compose file exists.
 
 %v
 `
+	errDetailNoDiscover = `
+Squire was requested to load a Docker Compose file and to not use any defaults.
+No Docker Compose file was found in the current directory or any parent
+directory. Squire looks for compose.yaml, compose.yml, docker-compose.yaml, or
+docker-compose.yml, or the files listed in the COMPOSE_FILE environment
+variable.
+`
+
+	errDetailComposeFile = `
+The COMPOSE_FILE environment variable (or ".env" file) lists the Docker
+Compose files for this project, but one of them could not be read. Please
+check that every file exists. Relative paths are relative to the current
+directory and multiple files are separated by COMPOSE_PATH_SEPARATOR (or
+":" on Linux and macOS, ";" on Windows).
+
+COMPOSE_FILE=%s
+`
+
 	errDetailNoService = `
 Could not find the PostgreSQL service in the Compose file! Squire looks for
 the database service by looking for a service with the "x-squire" extension
@@ -21,14 +39,22 @@ services:
 
 	errDetailMultiService = `
 Multiple services in your Compose file were marked as PostgreSQL services
-for Squire. Squire currently only supports exactly one service marked
-as a service.
+for Squire, so Squire doesn't know which one to use.
 
 Conflicting services: %[1]q, %[2]q
 
-To fix this, remove the "x-squire" configuration from one of the services.
-You should only have the "x-squire" configuration on the service you want to
-use as the database service.
+If your project has multiple databases, configure each one in the "databases"
+setting of your Squire configuration and choose one with "-db <name>".
+Otherwise, remove the "x-squire" configuration from all but the service you
+want to use as the database service.
+`
+
+	errDetailNamedService = `
+The service %q is configured as a database in the "databases" setting of
+your Squire configuration, but there is no service with that name in your
+Docker Compose file (or no Docker Compose file was found). Please check that
+the "service" setting of the database matches the name of a service. The
+service must also be enabled by the active Compose profiles, if it has any.
 `
 	errDetailNoPort = `
 Squire needs to know the port to use to communicate with the PostgreSQL
@@ -72,5 +98,13 @@ services:
   %[1]s:
     environment:
       - POSTGRES_DB=myapp
+`
+
+	errDetailPasswordFile = `
+The PostgreSQL service %q sets POSTGRES_PASSWORD_FILE, so Squire needs to
+read the password from that file to connect. Squire reads the file of the
+matching Docker Compose secret, or otherwise the same path on the host
+(relative to the Docker Compose file). You can also set the password
+directly with the "password" setting in the "x-squire" configuration.
 `
 )
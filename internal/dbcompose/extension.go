@@ -14,6 +14,14 @@ import (
 type composeExtension struct {
 	DB         string `mapstructure:"db"`
 	TargetPort uint32 `mapstructure:"targetPort"`
+
+	// Connection settings. These default to the POSTGRES_USER and
+	// POSTGRES_PASSWORD (or POSTGRES_PASSWORD_FILE) environment variables
+	// of the service, and "localhost".
+	User     string `mapstructure:"user"`
+	Password string `mapstructure:"password"`
+	Host     string `mapstructure:"host"`
+	SSLMode  string `mapstructure:"sslmode"`
 }
 
 // parseExtension parses our extension configuration from the given
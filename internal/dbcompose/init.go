@@ -2,8 +2,10 @@ package dbcompose
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/url"
+	"path/filepath"
 	"strings"
 
 	"github.com/cockroachdb/errors"
@@ -12,19 +14,41 @@ import (
 )
 
 const (
-	labelSquire = "com.mitchellh.squire"
+	// LabelSquire is set on every database service that Squire starts
+	// so that it can be found later (i.e. for destruction).
+	LabelSquire = "com.mitchellh.squire"
+
+	// LabelPool is the label set on pool clones (see dbcontainer.Pool). The
+	// value is the name (see Config.Name) of the config the clone was
+	// created from.
+	LabelPool = LabelSquire + ".pool"
+
+	// LabelClone is the label set on every clone (see Config.Clone). The
+	// value is the name (see Config.Name) of the config the clone was
+	// created from.
+	LabelClone = LabelSquire + ".clone"
 )
 
-// loadFromFile loads a project from a file.
-func loadFromFile(path string) (*types.Project, error) {
+// loadFromFiles loads a project from one or more files, merged in order.
+// The project directory is the directory of the first file. Services that
+// don't match the given profiles are disabled.
+func loadFromFiles(paths []string, profiles []string) (*types.Project, error) {
 	opts, err := composecli.NewProjectOptions(
-		[]string{path},
+		paths,
+		composecli.WithDotEnv,
+		composecli.WithOsEnv,
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	return composecli.ProjectFromOptions(opts)
+	p, err := composecli.ProjectFromOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	p.ApplyProfiles(profiles)
+
+	return p, nil
 }
 
 // init should be called once project and logger are set to populate
@@ -32,7 +56,7 @@ func loadFromFile(path string) (*types.Project, error) {
 // valid.
 func (c *Config) init() error {
 	// Grab our service
-	svc, err := service(c.project)
+	svc, err := service(c.project, c.serviceName)
 	if err != nil {
 		return err
 	}
@@ -42,24 +66,57 @@ func (c *Config) init() error {
 	if svc.Labels == nil {
 		svc.Labels = map[string]string{}
 	}
-	svc.Labels[labelSquire] = "1"
+	svc.Labels[LabelSquire] = "1"
 
 	// Get connection URL
-	uri, err := connURI(svc)
+	uri, err := connURI(c.project, svc, c.host)
 	if err != nil {
 		return err
 	}
 	c.connURI = uri
 
+	// Replace the database if we're connecting to another one on the service
+	if c.database != "" {
+		u, err := url.Parse(uri)
+		if err != nil {
+			return err
+		}
+		u.Path = c.database
+		c.connURI = u.String()
+	}
+
+	// Get the port within the container
+	port, err := _pgPort(svc)
+	if err != nil {
+		return err
+	}
+	c.targetPort = port.Target
+
 	return nil
 }
 
 // service returns the service configuration for the service representing
-// the database. This returns a pointer to the exact slice element in the
-// project so it is important to be aware of modifications.
+// the database. If name is non-empty, this is the service with that name.
+// Otherwise, this is the single service with the "x-squire" extension. This
+// returns a pointer to the exact slice element in the project so it is
+// important to be aware of modifications.
 //
 // Precondition: c.project != nil
-func service(p *types.Project) (*types.ServiceConfig, error) {
+func service(p *types.Project, name string) (*types.ServiceConfig, error) {
+	if name != "" {
+		for i, s := range p.Services {
+			if s.Name == name {
+				return &p.Services[i], nil
+			}
+		}
+
+		return nil, errors.WithDetailf(
+			errors.Newf("failed to find service %q in compose specification", name),
+			strings.TrimSpace(errDetailNamedService),
+			name,
+		)
+	}
+
 	var result *types.ServiceConfig
 	for i, s := range p.Services {
 		if len(s.Extensions) == 0 {
@@ -95,8 +152,9 @@ func service(p *types.Project) (*types.ServiceConfig, error) {
 	)
 }
 
-// connURI determines the connection URI.
-func connURI(svc *types.ServiceConfig) (string, error) {
+// connURI determines the connection URI. The host is used if the
+// extension doesn't specify one, and defaults to "localhost".
+func connURI(p *types.Project, svc *types.ServiceConfig, defaultHost string) (string, error) {
 	// Determine our port
 	pgPort, err := pgPort(svc)
 	if err != nil {
@@ -109,15 +167,124 @@ func connURI(svc *types.ServiceConfig) (string, error) {
 		return "", err
 	}
 
+	// Determine our credentials
+	user, password, err := pgCredentials(p, svc)
+	if err != nil {
+		return "", err
+	}
+
+	ext, err := parseExtension(svc)
+	if err != nil {
+		return "", err
+	}
+	host := ext.Host
+	if host == "" {
+		host = defaultHost
+	}
+	if host == "" {
+		host = "localhost"
+	}
+
 	var u url.URL
 	u.Scheme = "postgres"
-	u.Host = fmt.Sprintf("localhost:%d", pgPort)
-	u.User = url.User("postgres")
+	u.Host = net.JoinHostPort(host, fmt.Sprint(pgPort))
+	u.User = url.User(user)
+	if password != "" {
+		u.User = url.UserPassword(user, password)
+	}
 	u.Path = pgDB
+	if ext.SSLMode != "" {
+		u.RawQuery = url.Values{"sslmode": []string{ext.SSLMode}}.Encode()
+	}
 
 	return u.String(), nil
 }
 
+// pgCredentials determines the user and password to connect with. The
+// password may be empty, i.e. for trust authentication.
+func pgCredentials(p *types.Project, svc *types.ServiceConfig) (string, string, error) {
+	ext, err := parseExtension(svc)
+	if err != nil {
+		return "", "", err
+	}
+
+	user := ext.User
+	if user == "" {
+		user = pgEnv(svc, pgUserEnv)
+	}
+	if user == "" {
+		user = pgDefaultUser
+	}
+
+	password := ext.Password
+	if password == "" {
+		password = pgEnv(svc, pgPasswordEnv)
+	}
+	if password == "" {
+		if path := pgEnv(svc, pgPasswordFileEnv); path != "" {
+			password, err = pgPasswordFile(p, svc, path)
+			if err != nil {
+				return "", "", err
+			}
+		}
+	}
+
+	return user, password, nil
+}
+
+// pgPasswordFile reads the password from the file at path, which is a path
+// within the container. This is usually a Docker Compose secret, in which
+// case the secret file is read from the host. Otherwise, the path is read
+// from the host if it exists there, relative to the project directory.
+func pgPasswordFile(p *types.Project, svc *types.ServiceConfig, path string) (string, error) {
+	hostPath := ""
+	for _, ref := range svc.Secrets {
+		target := ref.Target
+		if target == "" {
+			target = ref.Source
+		}
+		if !strings.HasPrefix(target, "/") {
+			target = "/run/secrets/" + target
+		}
+		if target != path {
+			continue
+		}
+
+		if secret, ok := p.Secrets[ref.Source]; ok && secret.File != "" {
+			hostPath = secret.File
+		}
+		break
+	}
+	if hostPath == "" {
+		hostPath = path
+	}
+	if !filepath.IsAbs(hostPath) {
+		hostPath = filepath.Join(p.WorkingDir, hostPath)
+	}
+
+	bs, err := ioutil.ReadFile(hostPath)
+	if err != nil {
+		return "", errors.WithDetailf(
+			errors.Newf("failed to read %s for service %q: %w", pgPasswordFileEnv, svc.Name, err),
+			strings.TrimSpace(errDetailPasswordFile),
+			svc.Name,
+		)
+	}
+
+	return strings.TrimRight(string(bs), "\r\n"), nil
+}
+
+// pgEnv returns the value of the environment variable k for the service,
+// or an empty string if it isn't set.
+func pgEnv(svc *types.ServiceConfig, k string) string {
+	v, ok := svc.Environment[k]
+	if !ok || v == nil {
+		return ""
+	}
+
+	return *v
+}
+
 // pgPort determines the port for the database on the host.
 //
 // This works by looking for a port forward from port 5432 (the default pg port)
@@ -141,19 +308,28 @@ func pgReplacePort(svc *types.ServiceConfig, v uint32) error {
 	}
 
 	if v == 0 {
-		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		v, err = FreePort()
 		if err != nil {
 			return err
 		}
-		defer ln.Close()
-
-		v = uint32(ln.Addr().(*net.TCPAddr).Port)
 	}
 
 	port.Published = v
 	return nil
 }
 
+// FreePort returns a TCP port on localhost that is available at the time
+// of the function call.
+func FreePort() (uint32, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer ln.Close()
+
+	return uint32(ln.Addr().(*net.TCPAddr).Port), nil
+}
+
 // _pgPort is the helper shared by other functions to get a pointer directly
 // to the port forwarding configuration for accessing the database.
 func _pgPort(svc *types.ServiceConfig) (*types.ServicePortConfig, error) {
@@ -207,10 +383,59 @@ func pgDB(svc *types.ServiceConfig) (string, error) {
 	)
 }
 
+// pgFast modifies the service (in-place) to run PostgreSQL without
+// durability. This appends server settings to the command and moves the
+// data directory onto tmpfs. Any volume mounted at the data directory is
+// removed since it would conflict with the tmpfs mount.
+func pgFast(svc *types.ServiceConfig) {
+	cmd := svc.Command
+	if len(cmd) == 0 {
+		cmd = types.ShellCommand{"postgres"}
+	}
+	svc.Command = append(append(types.ShellCommand{}, cmd...), pgFastArgs...)
+
+	dataDir := pgDefaultDataDir
+	if v, ok := svc.Environment[pgDataEnv]; ok && v != nil && *v != "" {
+		dataDir = *v
+	}
+
+	volumes := make([]types.ServiceVolumeConfig, 0, len(svc.Volumes))
+	for _, v := range svc.Volumes {
+		if v.Target != dataDir {
+			volumes = append(volumes, v)
+		}
+	}
+	svc.Volumes = volumes
+	svc.Tmpfs = append(append(types.StringList{}, svc.Tmpfs...), dataDir)
+}
+
+// pgFastArgs are the arguments appended to the PostgreSQL command by pgFast.
+var pgFastArgs = []string{
+	"-c", "fsync=off",
+	"-c", "synchronous_commit=off",
+	"-c", "full_page_writes=off",
+}
+
 const (
 	// pgDefaultPort is the port for the PostgreSQL database.
 	pgDefaultPort = 5432
 
+	// pgDefaultDataDir is the default data directory in the official image.
+	pgDefaultDataDir = "/var/lib/postgresql/data"
+
+	// pgDataEnv is the env var in the container that specifies the
+	// data directory.
+	pgDataEnv = "PGDATA"
+
 	// pgDBEnv is the env var in the container that specifies the DB name.
 	pgDBEnv = "POSTGRES_DB"
+
+	// pgDefaultUser is the user if none is specified, the superuser that
+	// the official image creates by default.
+	pgDefaultUser = "postgres"
+
+	// These are the env vars in the container that specify the credentials.
+	pgUserEnv         = "POSTGRES_USER"
+	pgPasswordEnv     = "POSTGRES_PASSWORD"
+	pgPasswordFileEnv = "POSTGRES_PASSWORD_FILE"
 )
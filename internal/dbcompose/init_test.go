@@ -10,11 +10,11 @@ func TestPgReplacePort(t *testing.T) {
 	require := require.New(t)
 
 	// Load a project
-	p, err := loadFromFile("testdata/compose-v2.yml")
+	p, err := loadFromFiles([]string{"testdata/compose-v2.yml"}, nil)
 	require.NoError(err)
 
 	// Get our usual service
-	svc, err := service(p)
+	svc, err := service(p, "")
 	require.NoError(err)
 
 	// Get our typical port
@@ -1,6 +1,8 @@
 package dbcompose
 
 import (
+	"os"
+
 	"github.com/compose-spec/compose-go/types"
 	"github.com/hashicorp/go-hclog"
 )
@@ -8,15 +10,35 @@ import (
 // Option is used to configure the New function.
 type Option func(*options)
 
-// WithPath adds a path to the search path for a docker compose file. If
-// it isn't found, it is not an error if Default is set. If Default is NOT
-// set, then it will error.
+// WithPath adds a path to a docker compose file. Paths that don't exist
+// are skipped and the rest are merged in the order given. If none exist, it
+// is not an error if Default is set. If Default is NOT set, then it will
+// error. If any path is given, WithDiscover is ignored.
 func WithPath(v string) Option {
 	return func(final *options) {
 		final.Paths = append(final.Paths, v)
 	}
 }
 
+// WithDiscover finds the docker compose files using the Compose
+// specification rules starting at dir (see Discover). If no files are
+// found, it is not an error if Default is set. COMPOSE_PROFILES is also
+// respected unless WithProfiles is set.
+func WithDiscover(dir string) Option {
+	return func(final *options) {
+		final.Discover = true
+		final.DiscoverDir = dir
+	}
+}
+
+// WithProfiles sets the Compose profiles to enable. Services with profiles
+// that aren't enabled are ignored.
+func WithProfiles(v ...string) Option {
+	return func(final *options) {
+		final.Profiles = append(final.Profiles, v...)
+	}
+}
+
 // WithLogger specifies a logger to use. If this is not set, we will use
 // the default logger with the name "container".
 func WithLogger(v hclog.Logger) Option {
@@ -27,15 +49,92 @@ func WithLogger(v hclog.Logger) Option {
 
 // WithDefault specifies a default project to use if no path resolves.
 func WithDefault(v *types.Project) Option {
+	return WithDefaultFunc(func() (*types.Project, error) {
+		return v, nil
+	})
+}
+
+// WithDefaultFunc is like WithDefault, but the default project is only
+// created if no path resolves. This is useful if creating the default
+// project has side effects, such as choosing and saving a port.
+func WithDefaultFunc(f func() (*types.Project, error)) Option {
 	return func(final *options) {
-		final.Default = v
+		final.Default = f
+	}
+}
+
+// WithService selects the database service by name. By default, the
+// database service is the single service with the "x-squire" extension.
+// This allows a project to have multiple database services.
+func WithService(v string) Option {
+	return func(final *options) {
+		final.Service = v
+	}
+}
+
+// WithFastClones configures clones (see Config.Clone) to run PostgreSQL
+// without durability: fsync, synchronous_commit, and full_page_writes are
+// disabled and the data directory is on tmpfs. This never applies to the
+// original service.
+//
+// If this isn't set, fast clones are only used for the default project
+// (see WithDefault), since they replace the command of the service and
+// user-supplied images may use a different one.
+func WithFastClones(v bool) Option {
+	return func(final *options) {
+		final.FastClones = &v
 	}
 }
 
 type options struct {
-	Logger  hclog.Logger
-	Paths   []string
-	Default *types.Project
+	Logger      hclog.Logger
+	Paths       []string
+	Discover    bool
+	DiscoverDir string
+	Profiles    []string
+	Service     string
+	Default     func() (*types.Project, error)
+	FastClones  *bool
+}
+
+// files returns the existing compose files to load in order, along with
+// the profiles to enable.
+func (v *options) files() ([]string, []string, error) {
+	L := v.Logger
+	profiles := v.Profiles
+
+	var result []string
+	if len(v.Paths) > 0 {
+		L.Info("looking for compose file", "paths", v.Paths)
+		for _, p := range v.Paths {
+			_, err := os.Stat(p)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				L.Error("error reading compose file", "path", p, "err", err)
+				return nil, nil, err
+			}
+
+			result = append(result, p)
+		}
+	} else if v.Discover {
+		env, err := discoverEnv(v.DiscoverDir)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		result, err = discoverFiles(v.DiscoverDir, env)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if len(profiles) == 0 {
+			profiles = discoverProfiles(env)
+		}
+	}
+
+	return result, profiles, nil
 }
 
 // SetDefaults should be called to set all default values on options.
@@ -0,0 +1,24 @@
+package dbcontainer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// CheckpointPrefix is the prefix of all checkpoint databases for the
+// database dbname. Checkpoints are copies of a database that are kept on
+// the same server to make resets faster, and are dropped along with the
+// database by Container.Down.
+//
+// PostgreSQL truncates identifiers to 63 bytes so long database names are
+// truncated to leave room for the rest of the name. The hash of the full
+// name keeps databases that only differ after that apart, since stale
+// checkpoints are found (and dropped) by this prefix.
+func CheckpointPrefix(dbname string) string {
+	sum := sha256.Sum256([]byte(dbname))
+	if len(dbname) > 24 {
+		dbname = dbname[:24]
+	}
+
+	return dbname + "_" + hex.EncodeToString(sum[:])[:8] + "_ckpt_"
+}
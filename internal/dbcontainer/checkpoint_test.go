@@ -0,0 +1,24 @@
+package dbcontainer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpointPrefix(t *testing.T) {
+	require := require.New(t)
+
+	short := CheckpointPrefix("squire")
+	require.True(strings.HasPrefix(short, "squire_"))
+	require.True(strings.HasSuffix(short, "_ckpt_"))
+
+	// Long names are truncated but still distinct
+	long := strings.Repeat("a", 100)
+	a, b := CheckpointPrefix(long+"_oltp"), CheckpointPrefix(long+"_reporting")
+	require.True(strings.HasPrefix(a, strings.Repeat("a", 24)+"_"))
+	require.NotEqual(a, b)
+	require.False(strings.HasPrefix(a, b) || strings.HasPrefix(b, a))
+	require.LessOrEqual(len(a)+len("00_")+12, 63)
+}
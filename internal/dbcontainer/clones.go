@@ -0,0 +1,112 @@
+package dbcontainer
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	composeapi "github.com/docker/compose/v2/pkg/api"
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+
+	"github.com/mitchellh/squire/internal/dbcompose"
+)
+
+// CloneInfo is information about a clone of a container that exists.
+// See Container.Clones.
+type CloneInfo struct {
+	// Project is the name of the compose project of the clone, or the
+	// name of the cluster for the local backend. This is passed to
+	// Container.DownClone.
+	Project string
+
+	// Name is the name the clone was created with (see Clone).
+	Name string
+
+	// Created is when the clone was created. If the clone has more
+	// than one container, this is the oldest.
+	Created time.Time
+
+	// Pool is true if the clone is a member of a Pool.
+	Pool bool
+
+	// Ephemeral is true if the clone was created for the duration of
+	// a single operation (its name ends in a timestamp). Ephemeral clones
+	// that still exist after the operation were left behind, for example
+	// because Squire was killed.
+	Ephemeral bool
+}
+
+// Clones implements Container. Clones are found in Docker by their clone
+// label (see dbcompose.LabelClone), which is the name of this database
+// service (see dbcompose.Config.Name). The name of a clone is the rest of
+// the name of its project.
+func (c *Compose) Clones(ctx context.Context) ([]*CloneInfo, error) {
+	client, err := dockerClient()
+	if err != nil {
+		return nil, err
+	}
+
+	containers, err := client.ContainerList(ctx, dockertypes.ContainerListOptions{
+		All: true,
+		Filters: filters.NewArgs(filters.Arg(
+			"label", dbcompose.LabelClone+"="+c.config.Name())),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Compose lowercases project names.
+	prefix := strings.ToLower(c.config.Name()) + "-"
+
+	byProject := map[string]*CloneInfo{}
+	for _, ctr := range containers {
+		project := ctr.Labels[composeapi.ProjectLabel]
+		if project == "" {
+			continue
+		}
+
+		created := time.Unix(ctr.Created, 0)
+		if info, ok := byProject[project]; ok {
+			if created.Before(info.Created) {
+				info.Created = created
+			}
+
+			continue
+		}
+
+		name := strings.TrimPrefix(project, prefix)
+		_, pool := ctr.Labels[dbcompose.LabelPool]
+		byProject[project] = &CloneInfo{
+			Project:   project,
+			Name:      name,
+			Created:   created,
+			Pool:      pool,
+			Ephemeral: !pool && reEphemeralClone.MatchString(name),
+		}
+	}
+
+	result := make([]*CloneInfo, 0, len(byProject))
+	for _, info := range byProject {
+		result = append(result, info)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Project < result[j].Project
+	})
+
+	return result, nil
+}
+
+// DownClone implements Container.
+func (c *Compose) DownClone(ctx context.Context, project string) error {
+	c.logger.Info("down", "project", project)
+	return c.compose.Down(ctx, project, composeapi.DownOptions{
+		Volumes: true,
+	})
+}
+
+// reEphemeralClone matches the names of clones that are created for
+// a single operation, such as "diff-1634567890".
+var reEphemeralClone = regexp.MustCompile(`-\d+$`)
@@ -0,0 +1,208 @@
+package dbcontainer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	composeapi "github.com/docker/compose/v2/pkg/api"
+	"github.com/docker/compose/v2/pkg/compose"
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/mitchellh/squire/internal/dbcompose"
+)
+
+// Compose is a Container that runs the database service of a Docker
+// Compose project.
+type Compose struct {
+	logger  hclog.Logger
+	compose composeapi.Service
+	config  *dbcompose.Config
+}
+
+// newCompose creates a Compose container. If the Docker engine is remote
+// (i.e. DOCKER_HOST or the active Docker context points to another machine),
+// the compose configuration is updated to connect to that host. See
+// dbcompose.Config.SetHost.
+func newCompose(cfg *config) (*Compose, error) {
+	// Initialize our API service so we can run compose lifecycle ops
+	dockerCli, err := dockerCli()
+	if err != nil {
+		return nil, err
+	}
+	api := compose.NewComposeService(dockerCli.Client(), dockerCli.ConfigFile())
+
+	// If the Docker engine is remote, the database is published there.
+	if host := dockerHost(dockerCli.DockerEndpoint().Host); host != "" {
+		cfg.Logger.Debug("using remote docker host", "host", host)
+		if err := cfg.ComposeConfig.SetHost(host); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Compose{
+		logger:  cfg.Logger,
+		compose: api,
+		config:  cfg.ComposeConfig,
+	}, nil
+}
+
+// Clone implements Container.
+func (c *Compose) Clone(n string) (Container, error) {
+	cfg2, err := c.config.Clone(n)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Compose{
+		logger:  c.logger,
+		compose: c.compose,
+		config:  cfg2,
+	}, nil
+}
+
+// Database implements Container. The returned container shares the
+// compose project with this one.
+func (c *Compose) Database(name string) (Container, error) {
+	cfg2, err := c.config.Database(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Compose{
+		logger:  c.logger,
+		compose: c.compose,
+		config:  cfg2,
+	}, nil
+}
+
+// Config returns the underlying compose configuration.
+func (c *Compose) Config() *dbcompose.Config {
+	return c.config
+}
+
+// Name implements Container. See dbcompose.Config.Name.
+func (c *Compose) Name() string {
+	return c.config.Name()
+}
+
+// ConnURI implements Container. Validation of the connection information
+// is precomputed by dbcompose.New and any errors are reported then.
+func (c *Compose) ConnURI() string {
+	return c.config.ConnURI()
+}
+
+// Conn implements Container.
+func (c *Compose) Conn(ctx context.Context) (*sql.DB, error) {
+	return conn(ctx, c.ConnURI())
+}
+
+// Up implements Container.
+func (c *Compose) Up(ctx context.Context) error {
+	c.logger.Info("up", "service", c.config.Service())
+	return c.compose.Up(ctx, c.config.Project(), composeapi.UpOptions{
+		Create: composeapi.CreateOptions{
+			Services: []string{c.config.Service()},
+		},
+
+		Start: composeapi.StartOptions{},
+	})
+}
+
+// Down implements Container. Note that this will destroy ALL services in the docker-compose project,
+// we have no way to filter that out.
+func (c *Compose) Down(ctx context.Context) error {
+	p := c.config.Project()
+	c.logger.Info("down", "project", p.Name)
+	return c.compose.Down(ctx, p.Name, composeapi.DownOptions{
+		Project: p,
+		Volumes: true,
+	})
+}
+
+// Logs implements Container. These are the logs of the database service
+// container.
+func (c *Compose) Logs(ctx context.Context, w io.Writer, opts LogOptions) error {
+	tail := "all"
+	if opts.Tail > 0 {
+		tail = strconv.Itoa(opts.Tail)
+	}
+
+	return c.compose.Logs(ctx, c.config.Project().Name, &logConsumer{w: w}, composeapi.LogOptions{
+		Services: []string{c.config.Service()},
+		Tail:     tail,
+		Follow:   opts.Follow,
+	})
+}
+
+// logConsumer is a composeapi.LogConsumer that writes log lines to w
+// as-is, without the container name prefix of "docker compose logs".
+type logConsumer struct {
+	w io.Writer
+}
+
+func (c *logConsumer) Log(container, service, message string) {
+	fmt.Fprintln(c.w, message)
+}
+
+func (c *logConsumer) Status(container, msg string) {}
+func (c *logConsumer) Register(container string)    {}
+
+// Status implements Container.
+func (c *Compose) Status(ctx context.Context) (*Status, error) {
+	p := c.config.Project()
+	containers, err := c.compose.Ps(ctx, p.Name, composeapi.PsOptions{
+		Services: []string{c.config.Service()},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Not created
+	if len(containers) == 0 {
+		return &Status{State: NotCreated}, nil
+	}
+
+	// No idea what to make of this.
+	if len(containers) > 1 {
+		c.logger.Warn("more than one container on status", "containers", containers)
+	}
+
+	c0 := containers[0]
+	result := &Status{
+		ID:    c0.ID,
+		Name:  c0.Name,
+		State: State(strings.ToLower(c0.State)),
+	}
+
+	// Find the host port that maps to our database port.
+	for _, p := range c0.Publishers {
+		if p.TargetPort == int(c.config.TargetPort()) && p.PublishedPort > 0 {
+			result.Port = uint32(p.PublishedPort)
+			break
+		}
+	}
+
+	return result, nil
+}
+
+// Adopt implements Container.
+func (c *Compose) Adopt(ctx context.Context) (bool, error) {
+	st, err := c.Status(ctx)
+	if err != nil {
+		return false, err
+	}
+	if st.State != Running || st.Port == 0 {
+		return false, nil
+	}
+
+	c.logger.Debug("adopting running container", "id", st.ID, "port", st.Port)
+	if err := c.config.SetPort(st.Port); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
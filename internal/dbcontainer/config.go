@@ -24,11 +24,29 @@ func WithCompose(v *dbcompose.Config) Option {
 	}
 }
 
+// WithLocal configures the container to run with a locally installed
+// PostgreSQL rather than Docker. See Local.
+func WithLocal(v *LocalConfig) Option {
+	return func(cfg *config) {
+		cfg.LocalConfig = v
+	}
+}
+
+// WithExternal configures the container to be a database on an existing
+// PostgreSQL server rather than running a server. See External.
+func WithExternal(v *ExternalConfig) Option {
+	return func(cfg *config) {
+		cfg.ExternalConfig = v
+	}
+}
+
 // config is the configuration for the container. This must be constructed
 // and modified through various Option functions rather than directly.
 type config struct {
-	Logger        hclog.Logger
-	ComposeConfig *dbcompose.Config
+	Logger         hclog.Logger
+	ComposeConfig  *dbcompose.Config
+	LocalConfig    *LocalConfig
+	ExternalConfig *ExternalConfig
 }
 
 func newConfig(opts ...Option) (*config, error) {
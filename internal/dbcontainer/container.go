@@ -3,83 +3,133 @@ package dbcontainer
 import (
 	"context"
 	"database/sql"
-	"strings"
+	"io"
 	"time"
 
 	"github.com/cenkalti/backoff/v4"
-	composeapi "github.com/docker/compose/v2/pkg/api"
-	"github.com/docker/compose/v2/pkg/compose"
-	"github.com/hashicorp/go-hclog"
 	_ "github.com/jackc/pgx/v4/stdlib"
-
-	"github.com/mitchellh/squire/internal/dbcompose"
 )
 
-type Container struct {
-	logger  hclog.Logger
-	compose composeapi.Service
-	config  *dbcompose.Config
+// Container is a PostgreSQL server that Squire manages for development.
+// There are multiple implementations: Compose runs the server with Docker
+// Compose, Local runs a cluster with a locally installed PostgreSQL, and
+// External is a database on an existing server.
+// Use New to create the container for a configuration.
+//
+// Every container can be cloned (see Clone) to create another server with
+// the same settings but none of the data, which is used for tests, diffs,
+// and the Pool.
+type Container interface {
+	// Name returns a name for the container that is unique across projects.
+	// Clones are named after this.
+	Name() string
+
+	// ConnURI returns the connection string in URI format. This can be used
+	// with most PostgreSQL API clients. This can't fail because the
+	// connection information is validated when the container is created.
+	// Note its still possible for the connection itself to fail if the
+	// container isn't running, invalid information was provided, etc.
+	ConnURI() string
+
+	// Conn establishes a connection to the database and waits for the DB
+	// to become ready before returning.
+	//
+	// This creates a NEW connection. Callers must close the connection when
+	// they're done. This only works if the container is running.
+	Conn(ctx context.Context) (*sql.DB, error)
+
+	// Up starts the container. If it is already running, this does nothing.
+	Up(ctx context.Context) error
+
+	// Down stops the container and removes any data associated with it.
+	Down(ctx context.Context) error
+
+	// Status returns the status of the container.
+	//
+	// If the container is not created, a non-nil status will be returned
+	// with State == NotCreated. Other fields in the status in this case
+	// will be undefined (may be empty or not, but are meaningless and
+	// should not be depended on).
+	Status(ctx context.Context) (*Status, error)
+
+	// Adopt looks for a running container for this configuration and, if
+	// one is found, updates the configuration to match the port it is
+	// listening on. Clones are created with a random port, so this must be
+	// called to connect to a clone that was started by a previous Squire
+	// process. If no container is running, this does nothing and returns
+	// false.
+	Adopt(ctx context.Context) (bool, error)
+
+	// Logs writes the log of the PostgreSQL server to w. The container must
+	// have been created, but doesn't need to be running.
+	Logs(ctx context.Context, w io.Writer, opts LogOptions) error
+
+	// Clone clones the container settings. This does not copy any data.
+	// This does not create or start the cloned container.
+	Clone(n string) (Container, error)
+
+	// Database returns a container for a different logical database within
+	// the same container. The returned container shares the server with
+	// this one, so Up, Down, and Status operate on this same container.
+	// This does not create the database.
+	Database(name string) (Container, error)
+
+	// Clones returns all the clones of this container that exist, whether
+	// running or not. The result is sorted by project name.
+	Clones(ctx context.Context) ([]*CloneInfo, error)
+
+	// DownClone destroys the clone with the given project name (see
+	// Clones). This removes any data associated with it.
+	DownClone(ctx context.Context, project string) error
+
+	// Pool returns a pool of size clones of this container. This does not
+	// create or start any containers.
+	Pool(size int) *Pool
+
+	// poolMember returns the clone that is member i of the pool.
+	poolMember(i int) (Container, error)
+
+	// drainPool destroys every pool member, see Pool.Drain.
+	drainPool(ctx context.Context) ([]string, error)
+}
+
+// LogOptions are the options for Container.Logs.
+type LogOptions struct {
+	// Tail is the number of lines at the end of the log to write. If this
+	// is zero, the entire log is written.
+	Tail int
+
+	// Follow, if true, keeps writing new lines as they're logged until the
+	// context is cancelled.
+	Follow bool
 }
 
 // New creates a new Container instance to represent a new or existing
 // desired container. For new containers, this will not physically start
 // the container until Up is called.
-func New(opts ...Option) (*Container, error) {
+//
+// The container runs with Docker Compose (see WithCompose) unless the
+// local backend is configured with WithLocal or the external backend is
+// configured with WithExternal.
+func New(opts ...Option) (Container, error) {
 	cfg, err := newConfig(opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	// Initialize our API service so we can run compose lifecycle ops
-	dockerCli, err := dockerCli()
-	if err != nil {
-		return nil, err
+	if cfg.LocalConfig != nil {
+		return newLocal(cfg)
 	}
-	api := compose.NewComposeService(dockerCli.Client(), dockerCli.ConfigFile())
-
-	return &Container{
-		logger:  cfg.Logger,
-		compose: api,
-		config:  cfg.ComposeConfig,
-	}, nil
-}
-
-// Clone clones the container settings. This does not copy any data.
-// This does not create or start the cloned container.
-func (c *Container) Clone(n string) (*Container, error) {
-	cfg2, err := c.config.Clone(n)
-	if err != nil {
-		return nil, err
+	if cfg.ExternalConfig != nil {
+		return newExternal(cfg)
 	}
 
-	return &Container{
-		logger:  c.logger,
-		compose: c.compose,
-		config:  cfg2,
-	}, nil
-}
-
-// Config returns the underlying compose configuration.
-func (c *Container) Config() *dbcompose.Config {
-	return c.config
-}
-
-// ConnURI returns the connection string in URI format. This can be used
-// with most PostgreSQL API clients. This can't fail because validation of
-// the connection information is precomputed in New and any errors are
-// reported then. Note its still possible for the connection itself to fail
-// if the container isn't running, invalid information was provided, etc.
-func (c *Container) ConnURI() string {
-	return c.config.ConnURI()
+	return newCompose(cfg)
 }
 
-// Conn establishes a connection to the database and waits for the DB
-// to become ready before returning.
-//
-// This creates a NEW connection. Callers must close the connection when
-// they're done. This only works if the container is running.
-func (c *Container) Conn(ctx context.Context) (*sql.DB, error) {
-	db, err := sql.Open("pgx", c.ConnURI())
+// conn connects to the database at uri. See Container.Conn.
+func conn(ctx context.Context, uri string) (*sql.DB, error) {
+	db, err := sql.Open("pgx", uri)
 	if err != nil {
 		return nil, err
 	}
@@ -103,60 +153,3 @@ func (c *Container) Conn(ctx context.Context) (*sql.DB, error) {
 
 	return db, nil
 }
-
-// Up starts the container. If it is already running, this does nothing.
-func (c *Container) Up(ctx context.Context) error {
-	c.logger.Info("up", "service", c.config.Service())
-	return c.compose.Up(ctx, c.config.Project(), composeapi.UpOptions{
-		Create: composeapi.CreateOptions{
-			Services: []string{c.config.Service()},
-		},
-
-		Start: composeapi.StartOptions{},
-	})
-}
-
-// Down stops the container and removes any data associated with it.
-// Note that this will destroy ALL services in the docker-compose project,
-// we have no way to filter that out.
-func (c *Container) Down(ctx context.Context) error {
-	p := c.config.Project()
-	c.logger.Info("down", "project", p.Name)
-	return c.compose.Down(ctx, p.Name, composeapi.DownOptions{
-		Project: p,
-		Volumes: true,
-	})
-}
-
-// Status returns the status of the container.
-//
-// If the container is not created, a non-nil status will be returned
-// with State == NotCreated. Other fields in the status in this case
-// will be undefined (may be empty or not, but are meaningless and
-// should not be depended on).
-func (c *Container) Status(ctx context.Context) (*Status, error) {
-	p := c.config.Project()
-	containers, err := c.compose.Ps(ctx, p.Name, composeapi.PsOptions{
-		Services: []string{c.config.Service()},
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	// Not created
-	if len(containers) == 0 {
-		return &Status{State: NotCreated}, nil
-	}
-
-	// No idea what to make of this.
-	if len(containers) > 1 {
-		c.logger.Warn("more than one container on status", "containers", containers)
-	}
-
-	c0 := containers[0]
-	return &Status{
-		ID:    c0.ID,
-		Name:  c0.Name,
-		State: State(strings.ToLower(c0.State)),
-	}, nil
-}
@@ -1,6 +1,8 @@
 package dbcontainer
 
 import (
+	"net/url"
+
 	dockercommand "github.com/docker/cli/cli/command"
 	dockercliflags "github.com/docker/cli/cli/flags"
 	"github.com/docker/docker/client"
@@ -37,3 +39,23 @@ func dockerClient() (client.APIClient, error) {
 
 	return cli.Client(), nil
 }
+
+// dockerHost returns the host that ports published by the Docker engine
+// are reachable on, given the address of the engine. This is the endpoint
+// of the active Docker context or DOCKER_HOST. This returns an empty string
+// for local engines (unix sockets and named pipes), which are reachable on
+// localhost.
+func dockerHost(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return ""
+	}
+
+	switch u.Scheme {
+	case "tcp", "http", "https", "ssh":
+		return u.Hostname()
+
+	default:
+		return ""
+	}
+}
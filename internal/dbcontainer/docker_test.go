@@ -0,0 +1,22 @@
+package dbcontainer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDockerHost(t *testing.T) {
+	cases := map[string]string{
+		"unix:///var/run/docker.sock":    "",
+		"npipe:////./pipe/docker_engine": "",
+		"tcp://192.168.64.2:2376":        "192.168.64.2",
+		"ssh://me@build.example.com":     "build.example.com",
+		"tcp://[fd00::1]:2375":           "fd00::1",
+		"":                               "",
+	}
+
+	for endpoint, expected := range cases {
+		require.Equal(t, expected, dockerHost(endpoint), endpoint)
+	}
+}
@@ -0,0 +1,397 @@
+package dbcontainer
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cockroachdb/errors"
+	"github.com/hashicorp/go-hclog"
+	"github.com/jackc/pgx/v4"
+)
+
+// ExternalConfig configures an External container. See WithExternal.
+type ExternalConfig struct {
+	// Name is the name of the dev database. Clones are databases named
+	// after this, so this should have a prefix that is unique to Squire
+	// and the project. Names longer than PostgreSQL allows are shortened
+	// (see externalName).
+	Name string
+
+	// URL is the connection URL of a superuser on the server. The database
+	// in the URL is used to create and drop databases and defaults to
+	// "postgres".
+	URL string
+}
+
+// External is a Container that is a database on an existing PostgreSQL
+// server, such as a service container in CI where Docker isn't available.
+// Up and Down create and drop the database, and clones are other databases
+// on the same server. The server itself is never started or stopped.
+type External struct {
+	logger hclog.Logger
+	config ExternalConfig
+
+	// database, if set, overrides the database name in ConnURI. See
+	// Database.
+	database string
+
+	// clone is set if this is a clone. It is recorded in the comment of
+	// the database when it is created so that clones can be found. See
+	// Clones.
+	clone *externalClone
+}
+
+// externalClone is the comment of a database that is a clone, which has
+// the name of the database it was cloned from and the name of the clone.
+type externalClone struct {
+	Parent string `json:"parent"`
+	Name   string `json:"name"`
+}
+
+// newExternal creates an External container.
+func newExternal(cfg *config) (*External, error) {
+	ec := *cfg.ExternalConfig
+	if ec.Name == "" {
+		return nil, errors.New("external backend requires a database name")
+	}
+	ec.Name = externalName(ec.Name)
+	if _, err := url.Parse(ec.URL); err != nil {
+		return nil, errors.Wrap(err, "invalid external server URL")
+	}
+
+	return &External{
+		logger: cfg.Logger,
+		config: ec,
+	}, nil
+}
+
+// Name implements Container.
+func (e *External) Name() string {
+	return e.config.Name
+}
+
+// ConnURI implements Container.
+func (e *External) ConnURI() string {
+	db := e.config.Name
+	if e.database != "" {
+		db = e.database
+	}
+
+	return e.uri(db)
+}
+
+// Conn implements Container.
+func (e *External) Conn(ctx context.Context) (*sql.DB, error) {
+	return conn(ctx, e.ConnURI())
+}
+
+// Up implements Container. This creates the database if it doesn't exist.
+func (e *External) Up(ctx context.Context) error {
+	db, err := e.admin(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var exists bool
+	if err := db.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM pg_catalog.pg_database WHERE datname = $1)",
+		e.config.Name,
+	).Scan(&exists); err != nil || exists {
+		return err
+	}
+
+	e.logger.Info("up", "database", e.config.Name)
+	if _, err := db.ExecContext(ctx,
+		"CREATE DATABASE "+pgx.Identifier{e.config.Name}.Sanitize()); err != nil {
+		return err
+	}
+
+	if e.clone != nil {
+		bs, err := json.Marshal(e.clone)
+		if err != nil {
+			return err
+		}
+
+		// COMMENT doesn't accept parameters, so the comment is quoted.
+		comment := externalCloneComment + string(bs)
+		if _, err := db.ExecContext(ctx, "COMMENT ON DATABASE "+
+			pgx.Identifier{e.config.Name}.Sanitize()+" IS '"+
+			strings.ReplaceAll(comment, "'", "''")+"'"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Down implements Container. This drops the database and its checkpoints
+// (see CheckpointPrefix), disconnecting any sessions. Databases created
+// with Database are not dropped.
+func (e *External) Down(ctx context.Context) error {
+	db, err := e.admin(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx,
+		"SELECT datname FROM pg_catalog.pg_database WHERE starts_with(datname, $1)",
+		CheckpointPrefix(e.config.Name))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	names := []string{e.config.Name}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	e.logger.Info("down", "database", e.config.Name, "checkpoints", len(names)-1)
+	for _, name := range names {
+		if _, err := db.ExecContext(ctx,
+			"DROP DATABASE IF EXISTS "+pgx.Identifier{name}.Sanitize()+" WITH (FORCE)"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Status implements Container. The container is running if the database
+// exists. The ID is the OID of the database.
+func (e *External) Status(ctx context.Context) (*Status, error) {
+	db, err := e.admin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	result := &Status{Name: e.config.Name, State: Running}
+	err = db.QueryRowContext(ctx,
+		"SELECT oid::text FROM pg_catalog.pg_database WHERE datname = $1",
+		e.config.Name,
+	).Scan(&result.ID)
+	if err == sql.ErrNoRows {
+		return &Status{State: NotCreated}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if u, err := url.Parse(e.config.URL); err == nil {
+		port := u.Port()
+		if port == "" {
+			port = "5432"
+		}
+		if v, err := strconv.ParseUint(port, 10, 32); err == nil {
+			result.Port = uint32(v)
+		}
+	}
+
+	return result, nil
+}
+
+// Adopt implements Container. Databases are always on the same server
+// so there is nothing to update.
+func (e *External) Adopt(ctx context.Context) (bool, error) {
+	return false, nil
+}
+
+// Logs implements Container. The server isn't managed by Squire so its
+// log isn't available, and this always returns an error.
+func (e *External) Logs(ctx context.Context, w io.Writer, opts LogOptions) error {
+	return errors.WithDetail(
+		errors.New("server logs aren't available with the external backend"),
+		strings.TrimSpace(errDetailExternalLogs),
+	)
+}
+
+// Clone implements Container. The clone is another database on the
+// same server.
+func (e *External) Clone(n string) (Container, error) {
+	e2 := *e
+	e2.config.Name = externalName(fmt.Sprintf("%s-%s", e.config.Name, n))
+	e2.database = ""
+	e2.clone = &externalClone{Parent: e.config.Name, Name: n}
+
+	return &e2, nil
+}
+
+// Database implements Container.
+func (e *External) Database(name string) (Container, error) {
+	e2 := *e
+	e2.database = name
+
+	return &e2, nil
+}
+
+// Clones implements Container. Clones are the databases on the server
+// whose comment (see externalClone) has the name of this database as the
+// parent. Reading the creation time requires a superuser.
+func (e *External) Clones(ctx context.Context) ([]*CloneInfo, error) {
+	db, err := e.admin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	// PostgreSQL doesn't record when a database was created, but the
+	// PG_VERSION file in its directory is written once when it is.
+	rows, err := db.QueryContext(ctx, `
+SELECT datname, pg_catalog.shobj_description(oid, 'pg_database'),
+	(pg_catalog.pg_stat_file('base/' || oid || '/PG_VERSION')).modification
+FROM pg_catalog.pg_database
+WHERE starts_with(pg_catalog.shobj_description(oid, 'pg_database'), $1)
+ORDER BY datname`, externalCloneComment)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*CloneInfo
+	for rows.Next() {
+		var comment string
+		info := &CloneInfo{}
+		if err := rows.Scan(&info.Project, &comment, &info.Created); err != nil {
+			return nil, err
+		}
+
+		var clone externalClone
+		if err := json.Unmarshal(
+			[]byte(strings.TrimPrefix(comment, externalCloneComment)), &clone,
+		); err != nil || clone.Parent != e.config.Name {
+			continue
+		}
+
+		info.Name = clone.Name
+		info.Pool = strings.HasPrefix(info.Name, poolPrefix)
+		info.Ephemeral = !info.Pool && reEphemeralClone.MatchString(info.Name)
+		result = append(result, info)
+	}
+
+	return result, rows.Err()
+}
+
+// DownClone implements Container.
+func (e *External) DownClone(ctx context.Context, project string) error {
+	e2 := *e
+	e2.config.Name = project
+
+	return e2.Down(ctx)
+}
+
+// Pool implements Container.
+func (e *External) Pool(size int) *Pool {
+	return &Pool{logger: e.logger, base: e, size: size}
+}
+
+// poolMember implements Container.
+func (e *External) poolMember(i int) (Container, error) {
+	return e.Clone(fmt.Sprintf("%s%d", poolPrefix, i))
+}
+
+// drainPool implements Container.
+func (e *External) drainPool(ctx context.Context) ([]string, error) {
+	clones, err := e.Clones(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+	for _, info := range clones {
+		if !info.Pool {
+			continue
+		}
+
+		if err := e.DownClone(ctx, info.Project); err != nil {
+			return nil, err
+		}
+
+		result = append(result, info.Project)
+	}
+
+	return result, nil
+}
+
+// externalName returns name shortened to the maximum length of an
+// identifier in PostgreSQL, which otherwise silently truncates it. Long
+// names are cut short and end with a hash of the full name so that names
+// that only differ at the end remain different.
+func externalName(name string) string {
+	if len(name) <= maxIdentifierLen {
+		return name
+	}
+
+	sum := sha256.Sum256([]byte(name))
+	suffix := "_" + hex.EncodeToString(sum[:])[:8]
+
+	// Don't cut a multi-byte character in half.
+	n := maxIdentifierLen - len(suffix)
+	for n > 0 && !utf8.RuneStart(name[n]) {
+		n--
+	}
+
+	return name[:n] + suffix
+}
+
+// admin connects to the database of the configured URL, which is used
+// to create and drop databases.
+func (e *External) admin(ctx context.Context) (*sql.DB, error) {
+	return conn(ctx, e.uri(""))
+}
+
+// uri returns the configured URL with the database replaced with db. If
+// db is empty, the database of the URL is used, or "postgres" if it has
+// none.
+func (e *External) uri(db string) string {
+	u, err := url.Parse(e.config.URL)
+	if err != nil {
+		// Validated in newExternal
+		panic(err)
+	}
+
+	if db == "" {
+		db = strings.TrimPrefix(u.Path, "/")
+	}
+	if db == "" {
+		db = "postgres"
+	}
+	u.Path = db
+
+	return u.String()
+}
+
+// externalCloneComment starts the comment of every database that is a
+// clone, followed by the clone as JSON (see externalClone).
+const externalCloneComment = "Squire clone "
+
+// maxIdentifierLen is the maximum length of an identifier in bytes in
+// PostgreSQL (NAMEDATALEN - 1).
+const maxIdentifierLen = 63
+
+const (
+	errDetailExternalLogs = `
+With the "external" backend, the PostgreSQL server isn't run by Squire, so
+Squire can't read its log. View the log where the server runs instead, such
+as the logs of the CI service container.
+`
+)
@@ -0,0 +1,51 @@
+package dbcontainer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExternal(t *testing.T) {
+	require := require.New(t)
+
+	ctr, err := New(WithExternal(&ExternalConfig{
+		Name: "squire_app",
+		URL:  "postgres://postgres:secret@ci:5432/postgres?sslmode=disable",
+	}))
+	require.NoError(err)
+	require.Equal("squire_app", ctr.Name())
+	require.Equal("postgres://postgres:secret@ci:5432/squire_app?sslmode=disable", ctr.ConnURI())
+	require.Equal("postgres://postgres:secret@ci:5432/postgres?sslmode=disable", ctr.(*External).uri(""))
+
+	// Other databases are on the same server
+	ctr2, err := ctr.Database("other")
+	require.NoError(err)
+	require.Equal("postgres://postgres:secret@ci:5432/other?sslmode=disable", ctr2.ConnURI())
+	require.Equal(ctr.Name(), ctr2.Name())
+
+	// Clones are databases named after the dev database
+	clone, err := ctr2.Clone("test")
+	require.NoError(err)
+	require.Equal("squire_app-test", clone.Name())
+	require.Equal("postgres://postgres:secret@ci:5432/squire_app-test?sslmode=disable", clone.ConnURI())
+	require.Equal(&externalClone{Parent: "squire_app", Name: "test"}, clone.(*External).clone)
+
+	// Long names are shortened to fit in an identifier
+	long := "squire_" + strings.Repeat("a", 60)
+	ctr, err = New(WithExternal(&ExternalConfig{Name: long, URL: "postgres://ci"}))
+	require.NoError(err)
+	require.Len(ctr.Name(), maxIdentifierLen)
+	clone, err = ctr.Clone("diff-1634567890123456789")
+	require.NoError(err)
+	require.Len(clone.Name(), maxIdentifierLen)
+	clone2, err := ctr.Clone("diff-1634567890123456790")
+	require.NoError(err)
+	require.NotEqual(clone.Name(), clone2.Name())
+
+	// The admin database defaults to "postgres"
+	ctr, err = New(WithExternal(&ExternalConfig{Name: "squire_app", URL: "postgres://ci"}))
+	require.NoError(err)
+	require.Equal("postgres://ci/postgres", ctr.(*External).uri(""))
+}
@@ -0,0 +1,600 @@
+package dbcontainer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/hashicorp/go-hclog"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/mitchellh/squire/internal/dbcompose"
+)
+
+// LocalConfig configures a Local container. See WithLocal.
+type LocalConfig struct {
+	// Name is the name of the cluster. Clones are named after this.
+	Name string
+
+	// Dir is the directory that the data directories of the cluster and
+	// its clones are created in.
+	Dir string
+
+	// BinDir is the directory containing the PostgreSQL programs (initdb
+	// and pg_ctl). If this is empty, they are found on the PATH.
+	BinDir string
+
+	// Port is the port the cluster listens on. Clones listen on a free
+	// port chosen when they're started.
+	Port uint32
+
+	// DB is the name of the database to create and use.
+	DB string
+
+	// Settings are PostgreSQL server settings, i.e. {"log_statement": "all"}.
+	Settings map[string]string
+
+	// FastClones runs clones without durability. See
+	// dbcompose.WithFastClones.
+	FastClones bool
+}
+
+// Local is a Container that runs a PostgreSQL cluster directly on the host
+// using the initdb and pg_ctl programs of a locally installed PostgreSQL.
+// Every Local is a separate cluster with its own data directory within
+// LocalConfig.Dir, so clones are separate clusters listening on their own
+// ports. Clusters only listen on localhost and trust all connections.
+type Local struct {
+	logger hclog.Logger
+	config LocalConfig
+
+	// fast is true for clones if FastClones is set.
+	fast bool
+
+	// parent is the name of the cluster this is a clone of, or empty if
+	// this isn't a clone. See Clones.
+	parent string
+
+	// database, if set, overrides the database name in ConnURI. See
+	// Database.
+	database string
+}
+
+// newLocal creates a Local container.
+func newLocal(cfg *config) (*Local, error) {
+	lc := *cfg.LocalConfig
+	if lc.Name == "" || lc.Dir == "" {
+		return nil, errors.New("local backend requires a name and a directory")
+	}
+
+	return &Local{
+		logger: cfg.Logger,
+		config: lc,
+	}, nil
+}
+
+// Name implements Container.
+func (l *Local) Name() string {
+	return l.config.Name
+}
+
+// ConnURI implements Container.
+func (l *Local) ConnURI() string {
+	db := l.config.DB
+	if l.database != "" {
+		db = l.database
+	}
+
+	u := url.URL{
+		Scheme: "postgres",
+		User:   url.User(localUser),
+		Host:   net.JoinHostPort("localhost", strconv.FormatUint(uint64(l.config.Port), 10)),
+		Path:   db,
+	}
+
+	return u.String()
+}
+
+// Conn implements Container.
+func (l *Local) Conn(ctx context.Context) (*sql.DB, error) {
+	return conn(ctx, l.ConnURI())
+}
+
+// Up implements Container. The cluster is created with initdb if it
+// doesn't exist and then started with pg_ctl. The database is created
+// if it doesn't exist.
+func (l *Local) Up(ctx context.Context) error {
+	st, err := l.Status(ctx)
+	if err != nil {
+		return err
+	}
+	if st.State == Running {
+		return nil
+	}
+
+	l.logger.Info("up", "name", l.config.Name, "dir", l.dataDir())
+	if st.State == NotCreated {
+		if err := l.initdb(ctx); err != nil {
+			return err
+		}
+	}
+
+	// Clones don't have a port until they're started.
+	if l.config.Port == 0 {
+		l.config.Port, err = dbcompose.FreePort()
+		if err != nil {
+			return err
+		}
+	}
+
+	// Our settings are rewritten every time since the port of a clone
+	// changes every time it is started.
+	if err := ioutil.WriteFile(
+		filepath.Join(l.dataDir(), localConfFile), l.conf(), 0600,
+	); err != nil {
+		return err
+	}
+
+	if _, err := l.run(ctx, "pg_ctl",
+		"start", "-w", "-s", "-D", l.dataDir(), "-l", l.logPath(),
+	); err != nil {
+		return errors.WithDetailf(err,
+			"The PostgreSQL server log (%s) is below:\n\n%s",
+			l.logPath(), l.logTail(localLogLines),
+		)
+	}
+
+	return l.createDB(ctx)
+}
+
+// Down implements Container. The cluster is stopped and its data
+// directory is deleted.
+func (l *Local) Down(ctx context.Context) error {
+	st, err := l.Status(ctx)
+	if err != nil {
+		return err
+	}
+	if st.State == NotCreated {
+		return nil
+	}
+
+	l.logger.Info("down", "name", l.config.Name)
+	if st.State == Running {
+		if _, err := l.run(ctx, "pg_ctl",
+			"stop", "-w", "-s", "-m", "fast", "-D", l.dataDir(),
+		); err != nil {
+			return err
+		}
+	}
+
+	if err := os.RemoveAll(l.dataDir()); err != nil {
+		return err
+	}
+	if err := os.Remove(l.logPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// Status implements Container. The ID is the process ID of the server.
+func (l *Local) Status(ctx context.Context) (*Status, error) {
+	result := &Status{Name: l.config.Name}
+
+	_, err := os.Stat(filepath.Join(l.dataDir(), "PG_VERSION"))
+	if os.IsNotExist(err) {
+		result.State = NotCreated
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// pg_ctl status exits with 3 if the server isn't running.
+	result.State = Exited
+	if _, err := l.run(ctx, "pg_ctl", "status", "-D", l.dataDir()); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 3 {
+			return result, nil
+		}
+
+		return nil, err
+	}
+	result.State = Running
+
+	// The postmaster.pid file has the process ID on the first line and
+	// the port on the fourth.
+	bs, err := ioutil.ReadFile(filepath.Join(l.dataDir(), "postmaster.pid"))
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(string(bs), "\n")
+	if len(lines) >= 4 {
+		result.ID = strings.TrimSpace(lines[0])
+		if port, err := strconv.ParseUint(strings.TrimSpace(lines[3]), 10, 32); err == nil {
+			result.Port = uint32(port)
+		}
+	}
+
+	return result, nil
+}
+
+// Adopt implements Container.
+func (l *Local) Adopt(ctx context.Context) (bool, error) {
+	st, err := l.Status(ctx)
+	if err != nil {
+		return false, err
+	}
+	if st.State != Running || st.Port == 0 {
+		return false, nil
+	}
+
+	l.logger.Debug("adopting running cluster", "name", l.config.Name, "port", st.Port)
+	l.config.Port = st.Port
+	return true, nil
+}
+
+// Logs implements Container. The log file is polled for new lines when
+// following it.
+func (l *Local) Logs(ctx context.Context, w io.Writer, opts LogOptions) error {
+	f, err := os.Open(l.logPath())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Both of these leave f at the end of the log.
+	if opts.Tail > 0 {
+		if tail := tailLines(f, opts.Tail); tail != "" {
+			fmt.Fprintln(w, tail)
+		}
+	} else if _, err := io.Copy(w, f); err != nil {
+		return err
+	}
+
+	if !opts.Follow {
+		return nil
+	}
+
+	ticker := time.NewTicker(localLogPoll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case <-ticker.C:
+			if _, err := io.Copy(w, f); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Clone implements Container. The clone is a separate cluster.
+func (l *Local) Clone(n string) (Container, error) {
+	l2 := *l
+	l2.config.Name = fmt.Sprintf("%s-%s", l.config.Name, n)
+	l2.config.Port = 0
+	l2.fast = l.config.FastClones
+	l2.parent = l.config.Name
+	l2.database = ""
+
+	return &l2, nil
+}
+
+// Database implements Container. The returned container shares the
+// cluster with this one.
+func (l *Local) Database(name string) (Container, error) {
+	l2 := *l
+	l2.database = name
+
+	return &l2, nil
+}
+
+// Clones implements Container. Clones are the clusters in the same
+// directory whose parent file (see localParentFile) has the name of this
+// cluster. The name of a clone is the rest of the name of its cluster.
+func (l *Local) Clones(ctx context.Context) ([]*CloneInfo, error) {
+	entries, err := ioutil.ReadDir(l.config.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := l.config.Name + "-"
+
+	var result []*CloneInfo
+	for _, e := range entries {
+		if !e.IsDir() || !strings.HasPrefix(e.Name(), prefix) {
+			continue
+		}
+
+		parent, err := ioutil.ReadFile(filepath.Join(l.config.Dir, e.Name(), localParentFile))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if string(parent) != l.config.Name {
+			continue
+		}
+
+		// The PG_VERSION file is written by initdb and never modified.
+		fi, err := os.Stat(filepath.Join(l.config.Dir, e.Name(), "PG_VERSION"))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := strings.TrimPrefix(e.Name(), prefix)
+		pool := strings.HasPrefix(name, poolPrefix)
+		result = append(result, &CloneInfo{
+			Project:   e.Name(),
+			Name:      name,
+			Created:   fi.ModTime(),
+			Pool:      pool,
+			Ephemeral: !pool && reEphemeralClone.MatchString(name),
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Project < result[j].Project
+	})
+
+	return result, nil
+}
+
+// DownClone implements Container.
+func (l *Local) DownClone(ctx context.Context, project string) error {
+	l2 := *l
+	l2.config.Name = project
+
+	return l2.Down(ctx)
+}
+
+// Pool implements Container.
+func (l *Local) Pool(size int) *Pool {
+	return &Pool{logger: l.logger, base: l, size: size}
+}
+
+// poolMember implements Container.
+func (l *Local) poolMember(i int) (Container, error) {
+	return l.Clone(fmt.Sprintf("%s%d", poolPrefix, i))
+}
+
+// drainPool implements Container.
+func (l *Local) drainPool(ctx context.Context) ([]string, error) {
+	clones, err := l.Clones(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+	for _, info := range clones {
+		if !info.Pool {
+			continue
+		}
+
+		if err := l.DownClone(ctx, info.Project); err != nil {
+			return nil, err
+		}
+
+		result = append(result, info.Project)
+	}
+
+	return result, nil
+}
+
+// dataDir is the data directory of the cluster.
+func (l *Local) dataDir() string {
+	return filepath.Join(l.config.Dir, l.config.Name)
+}
+
+// logPath is the path to the server log of the cluster. This is outside
+// of the data directory so that it is never read by other tools.
+func (l *Local) logPath() string {
+	return filepath.Join(l.config.Dir, l.config.Name+".log")
+}
+
+// initdb creates the cluster.
+func (l *Local) initdb(ctx context.Context) error {
+	if err := os.MkdirAll(l.config.Dir, 0700); err != nil {
+		return err
+	}
+
+	args := []string{
+		"-D", l.dataDir(),
+		"-U", localUser,
+		"-A", "trust",
+		"-E", "UTF8",
+	}
+	if l.fast {
+		args = append(args, "--no-sync")
+	}
+	if _, err := l.run(ctx, "initdb", args...); err != nil {
+		return err
+	}
+
+	// Clones record their parent so that they are never confused with
+	// another cluster whose name has the same prefix.
+	if l.parent != "" {
+		if err := ioutil.WriteFile(
+			filepath.Join(l.dataDir(), localParentFile), []byte(l.parent), 0600,
+		); err != nil {
+			return err
+		}
+	}
+
+	// Include our settings (see conf) from the main configuration.
+	f, err := os.OpenFile(
+		filepath.Join(l.dataDir(), "postgresql.conf"), os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "\ninclude_if_exists = '%s'\n", localConfFile)
+	return err
+}
+
+// conf returns the contents of the configuration file that is included
+// from postgresql.conf.
+func (l *Local) conf() []byte {
+	settings := map[string]string{
+		"port":                    strconv.FormatUint(uint64(l.config.Port), 10),
+		"listen_addresses":        "localhost",
+		"unix_socket_directories": "",
+	}
+	if l.fast {
+		for _, k := range []string{"fsync", "synchronous_commit", "full_page_writes"} {
+			settings[k] = "off"
+		}
+	}
+	for k, v := range l.config.Settings {
+		settings[k] = v
+	}
+
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.WriteString("# Managed by Squire. This file is rewritten every time the server starts.\n")
+	for _, k := range keys {
+		fmt.Fprintf(&buf, "%s = '%s'\n", k, strings.ReplaceAll(settings[k], "'", "''"))
+	}
+
+	return buf.Bytes()
+}
+
+// createDB creates the configured database if it doesn't exist. The
+// cluster must be running.
+func (l *Local) createDB(ctx context.Context) error {
+	admin, err := l.Database("postgres")
+	if err != nil {
+		return err
+	}
+	db, err := admin.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var exists bool
+	if err := db.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM pg_catalog.pg_database WHERE datname = $1)",
+		l.config.DB,
+	).Scan(&exists); err != nil || exists {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, "CREATE DATABASE "+pgx.Identifier{l.config.DB}.Sanitize())
+	return err
+}
+
+// run runs one of the PostgreSQL programs and returns its output. If the
+// program fails, the error has the output as detail and wraps an
+// *exec.ExitError.
+func (l *Local) run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	path := name
+	if l.config.BinDir != "" {
+		path = filepath.Join(l.config.BinDir, name)
+	}
+	path, err := exec.LookPath(path)
+	if err != nil {
+		return nil, errors.WithDetailf(
+			errors.Newf("failed to find the PostgreSQL program %q: %w", name, err),
+			strings.TrimSpace(errDetailLocalBin),
+			name,
+		)
+	}
+
+	L := l.logger.Named("local")
+	L.Debug("running", "path", path, "args", args)
+	out, err := exec.CommandContext(ctx, path, args...).CombinedOutput()
+	if err != nil {
+		return out, errors.WithDetailf(
+			errors.Wrapf(err, "error running %s", name),
+			"The output is below:\n\n%s",
+			strings.TrimSpace(string(out)),
+		)
+	}
+
+	return out, nil
+}
+
+// logTail returns the last n lines of the server log.
+func (l *Local) logTail(n int) string {
+	f, err := os.Open(l.logPath())
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	return tailLines(f, n)
+}
+
+// tailLines reads r to the end and returns the last n lines.
+func tailLines(r io.Reader, n int) string {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		if len(lines) > n {
+			lines = lines[1:]
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+const (
+	// localUser is the superuser of local clusters.
+	localUser = "postgres"
+
+	// localConfFile is the name of the configuration file written by
+	// Squire within the data directory.
+	localConfFile = "squire.conf"
+
+	// localParentFile is the name of the file within the data directory of
+	// a clone that has the name of the cluster it was cloned from.
+	localParentFile = "squire_parent"
+
+	// localLogLines is the number of lines of the server log shown when
+	// the server fails to start.
+	localLogLines = 20
+
+	// localLogPoll is how often the server log is read for new lines when
+	// following it.
+	localLogPoll = 250 * time.Millisecond
+
+	errDetailLocalBin = `
+The "local" backend runs PostgreSQL using the programs of a locally installed
+PostgreSQL, but %q could not be found. Please install PostgreSQL (the server,
+not only the client) and make sure its programs are on your PATH, or set
+"dev.local.bin_dir" to the directory containing them, i.e.
+"/usr/lib/postgresql/13/bin".
+`
+)
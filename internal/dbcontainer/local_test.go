@@ -0,0 +1,195 @@
+package dbcontainer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mitchellh/squire/internal/dbcompose"
+)
+
+func TestLocal(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	ctr, err := New(WithLocal(&LocalConfig{
+		Name:       "app",
+		Dir:        t.TempDir(),
+		Port:       7890,
+		DB:         "squire",
+		FastClones: true,
+	}))
+	require.NoError(err)
+	require.Equal("postgres://postgres@localhost:7890/squire", ctr.ConnURI())
+
+	// Not created without a data directory
+	st, err := ctr.Status(ctx)
+	require.NoError(err)
+	require.Equal(NotCreated, st.State)
+
+	// Other databases share the cluster
+	ctr2, err := ctr.Database("other")
+	require.NoError(err)
+	require.Equal("postgres://postgres@localhost:7890/other", ctr2.ConnURI())
+	require.Equal(ctr.Name(), ctr2.Name())
+
+	// Clones are separate clusters without durability
+	clone, err := ctr.Clone("test")
+	require.NoError(err)
+	require.Equal("app-test", clone.Name())
+	require.True(clone.(*Local).fast)
+	require.Contains(string(clone.(*Local).conf()), "fsync = 'off'")
+	require.NotContains(string(ctr.(*Local).conf()), "fsync")
+}
+
+func TestLocal_clones(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	dir := t.TempDir()
+	for name, parent := range map[string]string{
+		"app":                 "",
+		"app-pool-0":          "app",
+		"app-diff-1634567890": "app",
+		"app-test":            "app",
+		"other-test":          "other",
+		"app-api":             "",
+		"app-api-test":        "app-api",
+	} {
+		require.NoError(os.MkdirAll(filepath.Join(dir, name), 0700))
+		require.NoError(ioutil.WriteFile(
+			filepath.Join(dir, name, "PG_VERSION"), []byte("13\n"), 0600))
+		if parent != "" {
+			require.NoError(ioutil.WriteFile(
+				filepath.Join(dir, name, localParentFile), []byte(parent), 0600))
+		}
+	}
+
+	// Not a cluster
+	require.NoError(os.MkdirAll(filepath.Join(dir, "app-empty"), 0700))
+
+	ctr, err := New(WithLocal(&LocalConfig{Name: "app", Dir: dir}))
+	require.NoError(err)
+
+	clones, err := ctr.Clones(ctx)
+	require.NoError(err)
+
+	var names []string
+	for _, c := range clones {
+		names = append(names, c.Name)
+	}
+	require.Equal([]string{"diff-1634567890", "pool-0", "test"}, names)
+	require.True(clones[0].Ephemeral)
+	require.True(clones[1].Pool)
+	require.False(clones[2].Pool || clones[2].Ephemeral)
+}
+
+func TestLocal_logs(t *testing.T) {
+	require := require.New(t)
+
+	dir := t.TempDir()
+	ctr, err := New(WithLocal(&LocalConfig{Name: "app", Dir: dir}))
+	require.NoError(err)
+
+	// Not created yet
+	var buf bytes.Buffer
+	require.Error(ctr.Logs(context.Background(), &buf, LogOptions{}))
+
+	path := filepath.Join(dir, "app.log")
+	require.NoError(ioutil.WriteFile(path, []byte("one\ntwo\nthree\n"), 0600))
+
+	require.NoError(ctr.Logs(context.Background(), &buf, LogOptions{}))
+	require.Equal("one\ntwo\nthree\n", buf.String())
+
+	buf.Reset()
+	require.NoError(ctr.Logs(context.Background(), &buf, LogOptions{Tail: 2}))
+	require.Equal("two\nthree\n", buf.String())
+
+	// Following writes new lines until cancelled. Closing the reader
+	// unblocks a pending write if the test fails before reading it.
+	ctx, cancel := context.WithCancel(context.Background())
+	r, w := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer w.Close()
+		ctr.Logs(ctx, w, LogOptions{Tail: 1, Follow: true})
+	}()
+	t.Cleanup(func() {
+		cancel()
+		r.Close()
+		<-done
+	})
+
+	scanner := bufio.NewScanner(r)
+	require.True(scanner.Scan())
+	require.Equal("three", scanner.Text())
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	require.NoError(err)
+	_, err = f.WriteString("four\n")
+	require.NoError(err)
+	require.NoError(f.Close())
+
+	require.True(scanner.Scan())
+	require.Equal("four", scanner.Text())
+}
+
+// This requires a local PostgreSQL installation and is skipped otherwise.
+func TestLocal_upDown(t *testing.T) {
+	if _, err := exec.LookPath("initdb"); err != nil {
+		t.Skip("initdb not found")
+	}
+
+	ctx := context.Background()
+	require := require.New(t)
+
+	port, err := dbcompose.FreePort()
+	require.NoError(err)
+	ctr, err := New(WithLocal(&LocalConfig{
+		Name: "app",
+		Dir:  t.TempDir(),
+		Port: port,
+		DB:   "squire",
+	}))
+	require.NoError(err)
+
+	defer func() {
+		require.NoError(ctr.Down(ctx))
+	}()
+	require.NoError(ctr.Up(ctx))
+
+	db, err := ctr.Conn(ctx)
+	require.NoError(err)
+	defer db.Close()
+	require.NoError(db.Ping())
+
+	st, err := ctr.Status(ctx)
+	require.NoError(err)
+	require.Equal(Running, st.State)
+	require.Equal(port, st.Port)
+
+	// Clones get their own cluster and port
+	clone, err := ctr.Clone("dup")
+	require.NoError(err)
+	defer clone.Down(ctx)
+	require.NoError(clone.Up(ctx))
+	require.NotEqual(ctr.ConnURI(), clone.ConnURI())
+
+	clones, err := ctr.Clones(ctx)
+	require.NoError(err)
+	require.Len(clones, 1)
+	require.NoError(ctr.DownClone(ctx, clones[0].Project))
+
+	st, err = clone.Status(ctx)
+	require.NoError(err)
+	require.Equal(NotCreated, st.State)
+}
@@ -0,0 +1,322 @@
+package dbcontainer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"sort"
+
+	"github.com/cockroachdb/errors"
+	composeapi "github.com/docker/compose/v2/pkg/api"
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/hashicorp/go-hclog"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/mitchellh/squire/internal/dbcompose"
+)
+
+// ErrPoolExhausted is returned by Pool.Lease if every container in the
+// pool is currently leased.
+var ErrPoolExhausted = errors.New("all pool containers are leased")
+
+// Pool is a pool of clones of a container that are kept running so that
+// they can be used without waiting for a container to start. A clone is
+// leased from the pool, used, and then returned to the pool rather than
+// being destroyed.
+//
+// Leases are tracked with a PostgreSQL advisory lock within each clone, so
+// they're safe to use across processes and are released automatically if
+// the process holding the lease exits.
+type Pool struct {
+	logger hclog.Logger
+	base   Container
+	size   int
+}
+
+// Pool implements Container.
+func (c *Compose) Pool(size int) *Pool {
+	return &Pool{logger: c.logger, base: c, size: size}
+}
+
+// Size returns the number of containers in the pool.
+func (p *Pool) Size() int {
+	return p.size
+}
+
+// Members returns the containers in the pool. These may or may not be
+// running. Running containers are adopted (see Container.Adopt).
+func (p *Pool) Members(ctx context.Context) ([]Container, error) {
+	result := make([]Container, p.size)
+	for i := range result {
+		ctr, err := p.base.poolMember(i)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := ctr.Adopt(ctx); err != nil {
+			return nil, err
+		}
+
+		result[i] = ctr
+	}
+
+	return result, nil
+}
+
+// Fill starts every container in the pool that isn't running.
+func (p *Pool) Fill(ctx context.Context) error {
+	members, err := p.Members(ctx)
+	if err != nil {
+		return err
+	}
+
+	var g errgroup.Group
+	for _, ctr := range members {
+		ctr := ctr
+		g.Go(func() error {
+			st, err := ctr.Status(ctx)
+			if err != nil {
+				return err
+			}
+			if st.State == Running {
+				return nil
+			}
+
+			return ctr.Up(ctx)
+		})
+	}
+
+	return g.Wait()
+}
+
+// Lease leases a container from the pool. The pool is filled first if
+// any of its containers aren't running. The lease must be returned with
+// Lease.Return when the caller is done with the container. The data in
+// the container is left as-is from its previous use, so callers should
+// reset the database. If every container is leased, ErrPoolExhausted
+// is returned.
+func (p *Pool) Lease(ctx context.Context) (*Lease, error) {
+	L := p.logger.Named("pool")
+
+	if err := p.Fill(ctx); err != nil {
+		return nil, err
+	}
+
+	members, err := p.Members(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ctr := range members {
+		lease, err := lease(ctx, ctr)
+		if err != nil {
+			return nil, err
+		}
+		if lease != nil {
+			L.Debug("leased pool container", "name", ctr.Name())
+			return lease, nil
+		}
+	}
+
+	return nil, ErrPoolExhausted
+}
+
+// Status returns the status of every container in the pool.
+func (p *Pool) Status(ctx context.Context) ([]*PoolStatus, error) {
+	members, err := p.Members(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*PoolStatus, len(members))
+	for i, ctr := range members {
+		st, err := ctr.Status(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		ps := &PoolStatus{Status: st}
+		if st.State == Running {
+			ps.Leased, err = leased(ctx, ctr)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		result[i] = ps
+	}
+
+	return result, nil
+}
+
+// Drain destroys every container in the pool, including containers from
+// a previously larger pool. Leased containers are destroyed as well.
+// The names of the destroyed projects are returned.
+func (p *Pool) Drain(ctx context.Context) ([]string, error) {
+	return p.base.drainPool(ctx)
+}
+
+// PoolStatus is the status of a single container in a Pool.
+type PoolStatus struct {
+	*Status
+
+	// Leased is true if the container is currently leased.
+	Leased bool
+}
+
+// Lease is a container leased from a Pool. Return must be called to
+// return the container to the pool.
+type Lease struct {
+	Container
+
+	db   *sql.DB
+	conn *sql.Conn
+}
+
+// Return returns the container to the pool. The container should not
+// be used after this.
+func (l *Lease) Return() error {
+	defer l.db.Close()
+	defer l.conn.Close()
+
+	_, err := l.conn.ExecContext(context.Background(),
+		"SELECT pg_catalog.pg_advisory_unlock($1, $2)",
+		poolLockKey, poolMemberKey(l.Container))
+	return err
+}
+
+// lease attempts to lease the given container. If the container is
+// already leased, this returns nil.
+func lease(ctx context.Context, ctr Container) (*Lease, error) {
+	db, err := poolAdminConn(ctx, ctr)
+	if err != nil {
+		return nil, err
+	}
+
+	// The advisory lock is held by the session so we need to hold
+	// a single connection for the lifetime of the lease.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	var ok bool
+	if err := conn.QueryRowContext(ctx,
+		"SELECT pg_catalog.pg_try_advisory_lock($1, $2)",
+		poolLockKey, poolMemberKey(ctr),
+	).Scan(&ok); err != nil || !ok {
+		conn.Close()
+		db.Close()
+		return nil, err
+	}
+
+	return &Lease{Container: ctr, db: db, conn: conn}, nil
+}
+
+// leased returns true if the given running container is leased.
+func leased(ctx context.Context, ctr Container) (bool, error) {
+	db, err := poolAdminConn(ctx, ctr)
+	if err != nil {
+		return false, err
+	}
+	defer db.Close()
+
+	var ok bool
+	err = db.QueryRowContext(ctx, `
+SELECT EXISTS (
+	SELECT 1 FROM pg_catalog.pg_locks
+	WHERE locktype = 'advisory' AND classid::bigint = $1
+		AND objid::bigint = $2 AND objsubid = 2 AND granted
+)`, poolLockKey, poolMemberKey(ctr)).Scan(&ok)
+	return ok, err
+}
+
+// poolMemberKey returns the second advisory lock key for the lease of a
+// pool container. Members of some backends share a server (see External),
+// so each member needs its own lock. This is positive so that it is stored
+// unchanged in the objid column of pg_locks.
+func poolMemberKey(ctr Container) int32 {
+	h := fnv.New32a()
+	h.Write([]byte(ctr.Name()))
+	return int32(h.Sum32() & 0x7fffffff)
+}
+
+// poolMember implements Container. Pool members are labeled so that they
+// can be found by drainPool.
+func (c *Compose) poolMember(i int) (Container, error) {
+	ctr, err := c.config.Clone(fmt.Sprintf("%s%d", poolPrefix, i))
+	if err != nil {
+		return nil, err
+	}
+	ctr.SetLabel(dbcompose.LabelPool, c.config.Name())
+
+	return &Compose{
+		logger:  c.logger,
+		compose: c.compose,
+		config:  ctr,
+	}, nil
+}
+
+// drainPool implements Container. Pool members are found by label so that
+// members of a previously larger pool are found as well.
+func (c *Compose) drainPool(ctx context.Context) ([]string, error) {
+	client, err := dockerClient()
+	if err != nil {
+		return nil, err
+	}
+
+	containers, err := client.ContainerList(ctx, dockertypes.ContainerListOptions{
+		All: true,
+		Filters: filters.NewArgs(filters.Arg(
+			"label", dbcompose.LabelPool+"="+c.config.Name())),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	projects := map[string]struct{}{}
+	for _, c := range containers {
+		if name := c.Labels[composeapi.ProjectLabel]; name != "" {
+			projects[name] = struct{}{}
+		}
+	}
+
+	result := make([]string, 0, len(projects))
+	for name := range projects {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+
+	for _, name := range result {
+		if err := c.DownClone(ctx, name); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// poolAdminConn connects to the "postgres" database within the container.
+// Leases are held there since the configured database is dropped when
+// the database is reset.
+func poolAdminConn(ctx context.Context, ctr Container) (*sql.DB, error) {
+	admin, err := ctr.Database("postgres")
+	if err != nil {
+		return nil, err
+	}
+
+	return admin.Conn(ctx)
+}
+
+const (
+	// poolPrefix is the prefix of the clone name of every pool member.
+	poolPrefix = "pool-"
+
+	// poolLockKey is the first advisory lock key held by the lease of a
+	// pool container, see poolMemberKey. This is stored in the classid
+	// column of pg_locks.
+	poolLockKey = 0x53717569
+)
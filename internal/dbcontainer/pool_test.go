@@ -0,0 +1,63 @@
+package dbcontainer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mitchellh/squire/internal/dbcompose"
+)
+
+func TestPool(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	cfg, err := dbcompose.New(dbcompose.WithPath("testdata/compose-v2.yml"))
+	require.NoError(err)
+	ctr, err := New(WithCompose(cfg))
+	require.NoError(err)
+
+	pool := ctr.Pool(1)
+	defer func() {
+		_, err := pool.Drain(ctx)
+		require.NoError(err)
+	}()
+
+	// Lease the only container
+	lease, err := pool.Lease(ctx)
+	require.NoError(err)
+	db, err := lease.Conn(ctx)
+	require.NoError(err)
+	require.NoError(db.Ping())
+	db.Close()
+
+	// Should be leased
+	sts, err := pool.Status(ctx)
+	require.NoError(err)
+	require.Len(sts, 1)
+	require.Equal(Running, sts[0].State)
+	require.True(sts[0].Leased)
+
+	// Can't lease another
+	_, err = pool.Lease(ctx)
+	require.ErrorIs(err, ErrPoolExhausted)
+
+	// Return it and we can lease it again
+	require.NoError(lease.Return())
+	sts, err = pool.Status(ctx)
+	require.NoError(err)
+	require.False(sts[0].Leased)
+
+	lease, err = pool.Lease(ctx)
+	require.NoError(err)
+	require.NoError(lease.Return())
+
+	// Drain
+	drained, err := pool.Drain(ctx)
+	require.NoError(err)
+	require.Len(drained, 1)
+	sts, err = pool.Status(ctx)
+	require.NoError(err)
+	require.Equal(NotCreated, sts[0].State)
+}
@@ -11,6 +11,10 @@ type Status struct {
 
 	// State of the container.
 	State State
+
+	// Port is the port on the host that the database is published on.
+	// This may be zero if the container isn't running.
+	Port uint32
 }
 
 // State is the possible states that a container can be in. The
@@ -4,14 +4,22 @@
 package dbdefault
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
+	"github.com/cockroachdb/errors"
+	"github.com/compose-spec/compose-go/loader"
 	"github.com/compose-spec/compose-go/types"
+
+	"github.com/mitchellh/squire/internal/config"
 )
 
-// Project gets the default Compose project.
-func Project() *types.Project {
+// Project gets the default Compose project. The project is customized by
+// the "dev" settings in the configuration.
+func Project(cfg *config.Config) (*types.Project, error) {
 	wd, err := os.Getwd()
 	if err != nil {
 		// We don't currently support environments where we don't have
@@ -25,25 +33,92 @@ func Project() *types.Project {
 	// still running.
 	projName := filepath.Base(wd) + "-default"
 
+	// Choose our port if it isn't set so that multiple projects (or a
+	// local PostgreSQL) can run at the same time.
+	port := uint32(cfg.Dev.Port)
+	if port == 0 {
+		port, err = statePort(projName, wd)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Environment. The user environment can override our defaults.
+	env := map[string]string{
+		"POSTGRES_DB":               cfg.Dev.DB,
+		"POSTGRES_HOST_AUTH_METHOD": "trust",
+	}
+	for k, v := range cfg.Dev.Env {
+		env[k] = v
+	}
+	envList := make([]string, 0, len(env))
+	for k, v := range env {
+		envList = append(envList, k+"="+v)
+	}
+	sort.Strings(envList)
+
+	// Server settings are passed as "-c" flags to postgres.
+	var command types.ShellCommand
+	if len(cfg.Dev.Settings) > 0 {
+		keys := make([]string, 0, len(cfg.Dev.Settings))
+		for k := range cfg.Dev.Settings {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		command = types.ShellCommand{"postgres"}
+		for _, k := range keys {
+			command = append(command, "-c", k+"="+cfg.Dev.Settings[k])
+		}
+	}
+
+	// Volumes use the Docker Compose short syntax.
+	projVolumes := map[string]types.VolumeConfig{}
+	var volumes []types.ServiceVolumeConfig
+	for _, spec := range cfg.Dev.Volumes {
+		v, err := loader.ParseVolume(spec)
+		if err != nil {
+			return nil, errors.WithDetail(
+				errors.Newf("invalid volume %q in dev.volumes: %w", spec, err),
+				strings.TrimSpace(errDetailVolume),
+			)
+		}
+
+		switch v.Type {
+		case types.VolumeTypeBind:
+			if !filepath.IsAbs(v.Source) {
+				v.Source = filepath.Join(wd, v.Source)
+			}
+
+		case types.VolumeTypeVolume:
+			if v.Source != "" {
+				projVolumes[v.Source] = types.VolumeConfig{
+					Name: fmt.Sprintf("%s_%s", projName, v.Source),
+				}
+			}
+		}
+
+		volumes = append(volumes, v)
+	}
+
 	return &types.Project{
 		Name:       projName,
 		WorkingDir: wd,
 		Services: []types.ServiceConfig{
 			{
-				Name:  "postgres",
-				Image: "postgres:13.4",
+				Name:    "postgres",
+				Image:   cfg.Dev.DefaultImage,
+				Command: command,
 				Ports: []types.ServicePortConfig{
 					{
 						Mode:      "ingress",
 						Target:    5432,
-						Published: 5432,
+						Published: port,
 						Protocol:  "tcp",
 					},
 				},
-				Environment: types.NewMappingWithEquals([]string{
-					"POSTGRES_DB=squire",
-					"POSTGRES_HOST_AUTH_METHOD=trust",
-				}),
+				Environment: types.NewMappingWithEquals(envList),
+				Volumes:     volumes,
 				Networks: map[string]*types.ServiceNetworkConfig{
 					"default": nil,
 				},
@@ -59,5 +134,16 @@ func Project() *types.Project {
 				Name: projName + "-net",
 			},
 		},
-	}
+
+		Volumes: projVolumes,
+	}, nil
 }
+
+const (
+	errDetailVolume = `
+Volumes in the "dev.volumes" configuration use the Docker Compose short
+syntax: "[source:]target[:mode]", i.e. "pgdata:/var/lib/postgresql/data" for
+a named volume or "./init:/docker-entrypoint-initdb.d:ro" for a directory
+relative to the current directory.
+`
+)
@@ -0,0 +1,191 @@
+package dbdefault
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/compose-spec/compose-go/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mitchellh/squire/internal/config"
+	"github.com/mitchellh/squire/internal/pkg/cachedir"
+)
+
+func TestProject_default(t *testing.T) {
+	require := require.New(t)
+	testCacheDir(t)
+
+	cfg, err := config.New()
+	require.NoError(err)
+
+	p, err := Project(cfg)
+	require.NoError(err)
+	require.Len(p.Services, 1)
+
+	svc := p.Services[0]
+	require.Equal("postgres:13.4", svc.Image)
+	require.Empty(svc.Command)
+	require.Empty(svc.Volumes)
+	require.NotZero(svc.Ports[0].Published)
+	require.Equal("squire", *svc.Environment["POSTGRES_DB"])
+	require.Equal("trust", *svc.Environment["POSTGRES_HOST_AUTH_METHOD"])
+}
+
+func TestProject_custom(t *testing.T) {
+	require := require.New(t)
+	testCacheDir(t)
+
+	cfg, err := config.New(config.FromString(`
+dev: {
+	default_image: "postgres:14"
+	port: 6543
+	db: "app"
+	env: POSTGRES_PASSWORD: "secret"
+	settings: {
+		log_statement: "all"
+		max_connections: "200"
+	}
+	volumes: ["pgdata:/var/lib/postgresql/data", "./init:/docker-entrypoint-initdb.d:ro"]
+}
+`))
+	require.NoError(err)
+
+	p, err := Project(cfg)
+	require.NoError(err)
+
+	svc := p.Services[0]
+	require.Equal("postgres:14", svc.Image)
+	require.Equal(uint32(6543), svc.Ports[0].Published)
+	require.Equal("app", *svc.Environment["POSTGRES_DB"])
+	require.Equal("secret", *svc.Environment["POSTGRES_PASSWORD"])
+	require.Equal(types.ShellCommand{
+		"postgres",
+		"-c", "log_statement=all",
+		"-c", "max_connections=200",
+	}, svc.Command)
+
+	wd, err := os.Getwd()
+	require.NoError(err)
+	require.Len(svc.Volumes, 2)
+	require.Equal(types.VolumeTypeVolume, svc.Volumes[0].Type)
+	require.Equal("pgdata", svc.Volumes[0].Source)
+	require.Equal(p.Name+"_pgdata", p.Volumes["pgdata"].Name)
+	require.Equal(types.VolumeTypeBind, svc.Volumes[1].Type)
+	require.Equal(filepath.Join(wd, "init"), svc.Volumes[1].Source)
+	require.True(svc.Volumes[1].ReadOnly)
+}
+
+func TestProject_invalidVolume(t *testing.T) {
+	require := require.New(t)
+	testCacheDir(t)
+
+	cfg, err := config.New(config.FromString(`dev: volumes: [""]`))
+	require.NoError(err)
+
+	_, err = Project(cfg)
+	require.Error(err)
+}
+
+func TestProject_port(t *testing.T) {
+	require := require.New(t)
+	dir := testCacheDir(t)
+
+	cfg, err := config.New()
+	require.NoError(err)
+
+	// The chosen port is persisted
+	p, err := Project(cfg)
+	require.NoError(err)
+	port := p.Services[0].Ports[0].Published
+	require.NotZero(port)
+	files, err := ioutil.ReadDir(filepath.Join(dir, "projects"))
+	require.NoError(err)
+	require.Len(files, 1)
+
+	// And used again
+	p, err = Project(cfg)
+	require.NoError(err)
+	require.Equal(port, p.Services[0].Ports[0].Published)
+
+	// An explicit port doesn't use the state
+	cfg, err = config.New(config.FromString(`dev: port: 5432`))
+	require.NoError(err)
+	p, err = Project(cfg)
+	require.NoError(err)
+	require.Equal(uint32(5432), p.Services[0].Ports[0].Published)
+}
+
+// testCacheDir replaces the cache directory with a temporary directory
+// for the duration of the test.
+func testCacheDir(t *testing.T) string {
+	dir := t.TempDir()
+	old := cachedir.Dir
+	cachedir.Dir = func() (string, error) { return dir, nil }
+	t.Cleanup(func() { cachedir.Dir = old })
+	return dir
+}
+
+func TestLocal(t *testing.T) {
+	require := require.New(t)
+	dir := testCacheDir(t)
+
+	cfg, err := config.New(config.FromString(`dev: {
+	backend: "local"
+	port: 5433
+}`))
+	require.NoError(err)
+
+	lc, err := Local(cfg, "")
+	require.NoError(err)
+	require.Equal(uint32(5433), lc.Port)
+	require.Equal("squire", lc.DB)
+	require.True(strings.HasPrefix(lc.Dir, filepath.Join(dir, "local")+string(filepath.Separator)))
+
+	// Each database is a separate cluster with its own port
+	lc2, err := Local(cfg, "reporting")
+	require.NoError(err)
+	require.Equal(lc.Name+"-reporting", lc2.Name)
+	require.NotEqual(uint32(5433), lc2.Port)
+	require.Equal(lc.Dir, lc2.Dir)
+
+	// A relative data directory is relative to the working directory
+	cfg, err = config.New(config.FromString(`dev: local: data_dir: ".squire-data"`))
+	require.NoError(err)
+	lc, err = Local(cfg, "")
+	require.NoError(err)
+	wd, err := os.Getwd()
+	require.NoError(err)
+	require.Equal(filepath.Join(wd, ".squire-data"), lc.Dir)
+}
+
+func TestExternal(t *testing.T) {
+	require := require.New(t)
+
+	cfg, err := config.New(config.FromString(`dev: {
+	backend: "external"
+	external: url_env: "SQUIRE_TEST_EXTERNAL_URL"
+}`))
+	require.NoError(err)
+
+	// The URL is required
+	t.Setenv("SQUIRE_TEST_EXTERNAL_URL", "")
+	_, err = External(cfg, "")
+	require.Error(err)
+
+	t.Setenv("SQUIRE_TEST_EXTERNAL_URL", "postgres://postgres@ci:5432/postgres")
+	ec, err := External(cfg, "")
+	require.NoError(err)
+	require.Equal("postgres://postgres@ci:5432/postgres", ec.URL)
+
+	wd, err := os.Getwd()
+	require.NoError(err)
+	require.Equal("squire_"+filepath.Base(wd), ec.Name)
+
+	// Each database is a separate dev database
+	ec2, err := External(cfg, "reporting")
+	require.NoError(err)
+	require.Equal(ec.Name+"_reporting", ec2.Name)
+}
@@ -0,0 +1,61 @@
+package dbdefault
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/mitchellh/squire/internal/config"
+	"github.com/mitchellh/squire/internal/dbcontainer"
+	"github.com/mitchellh/squire/internal/pkg/cachedir"
+)
+
+// Local gets the configuration for the "local" backend, which runs the
+// dev database as a cluster of a locally installed PostgreSQL. The
+// cluster is customized by the "dev" settings in the configuration.
+//
+// If db is non-empty, this is the cluster for that database of the
+// "databases" setting. Each database is a separate cluster and always
+// uses a port chosen automatically, since they can't share "dev.port".
+func Local(cfg *config.Config, db string) (*dbcontainer.LocalConfig, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		// We don't currently support environments where we don't have
+		// a working directory.
+		panic(err)
+	}
+
+	// The name has a suffix so that the port state never conflicts with
+	// the default Docker project.
+	name := filepath.Base(wd) + "-local"
+	if db != "" {
+		name += "-" + db
+	}
+
+	port := uint32(cfg.Dev.Port)
+	if port == 0 || db != "" {
+		port, err = statePort(name, wd)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	dir := cfg.Dev.Local.DataDir
+	if dir == "" {
+		dir, err = cachedir.ProjectPath("local", filepath.Base(wd), wd)
+		if err != nil {
+			return nil, err
+		}
+	} else if !filepath.IsAbs(dir) {
+		dir = filepath.Join(wd, dir)
+	}
+
+	return &dbcontainer.LocalConfig{
+		Name:       name,
+		Dir:        dir,
+		BinDir:     cfg.Dev.Local.BinDir,
+		Port:       port,
+		DB:         cfg.Dev.DB,
+		Settings:   cfg.Dev.Settings,
+		FastClones: cfg.Dev.FastClones == nil || *cfg.Dev.FastClones,
+	}, nil
+}
@@ -0,0 +1,71 @@
+package dbdefault
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/mitchellh/squire/internal/dbcompose"
+	"github.com/mitchellh/squire/internal/pkg/cachedir"
+)
+
+// state is the persisted state for the default project of a single
+// directory. This is stored outside of the directory so that it is never
+// checked into version control.
+type state struct {
+	// Port is the host port the database is published on.
+	Port uint32 `json:"port"`
+}
+
+// statePath returns the path to the state file for the default project
+// in the directory wd.
+func statePath(name, wd string) (string, error) {
+	path, err := cachedir.ProjectPath("projects", name, wd)
+	if err != nil {
+		return "", err
+	}
+
+	return path + ".json", nil
+}
+
+// statePort returns the host port for the default project. If no port has
+// been chosen yet, a free port is chosen and saved in the state file.
+func statePort(name, wd string) (uint32, error) {
+	path, err := statePath(name, wd)
+	if err != nil {
+		return 0, err
+	}
+
+	var st state
+	bs, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(bs, &st); err != nil {
+			return 0, err
+		}
+	}
+	if st.Port > 0 {
+		return st.Port, nil
+	}
+
+	st.Port, err = dbcompose.FreePort()
+	if err != nil {
+		return 0, err
+	}
+
+	bs, err = json.MarshalIndent(&st, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, err
+	}
+	if err := ioutil.WriteFile(path, bs, 0644); err != nil {
+		return 0, err
+	}
+
+	return st.Port, nil
+}
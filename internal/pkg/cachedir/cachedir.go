@@ -0,0 +1,47 @@
+// Package cachedir has helpers for the directory that Squire stores
+// per-user data in, such as state files. Data in this directory is never
+// checked into version control.
+package cachedir
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+)
+
+// Dir returns the directory that Squire stores per-user data in. This is
+// a variable so that tests can replace it.
+var Dir = func() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "squire"), nil
+}
+
+// ProjectPath returns a path within the subdirectory sub of the cache
+// directory for the project with the given name in the directory wd. The
+// name includes a hash of the full path since different directories can
+// have the same base name.
+func ProjectPath(sub, name, wd string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(wd))
+	return filepath.Join(dir, sub, name+"-"+hex.EncodeToString(sum[:])[:12]), nil
+}
+
+// Project returns a path within the subdirectory sub of the cache
+// directory that is unique to the project in the current directory.
+func Project(sub string) (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	return ProjectPath(sub, filepath.Base(wd), wd)
+}
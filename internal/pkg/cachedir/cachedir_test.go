@@ -0,0 +1,26 @@
+package cachedir
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjectPath(t *testing.T) {
+	require := require.New(t)
+
+	dir := t.TempDir()
+	old := Dir
+	Dir = func() (string, error) { return dir, nil }
+	t.Cleanup(func() { Dir = old })
+
+	a, err := ProjectPath("projects", "app", "/src/a/app")
+	require.NoError(err)
+	require.Equal(filepath.Join(dir, "projects"), filepath.Dir(a))
+
+	// The same name in another directory is another project
+	b, err := ProjectPath("projects", "app", "/src/b/app")
+	require.NoError(err)
+	require.NotEqual(a, b)
+}
@@ -29,10 +29,15 @@ type Config struct {
 
 	// Tests, if true, will include files ending in _test.sql. If
 	// TestsOnly is true, then ONLY test files are added, and non-test
-	// files are ignored.
+	// files are ignored. Files ending in _plan.sql, _snapshot.sql,
+	// _fixture.sql, _setup.sql, or _roles.sql are never included, see Files.
 	Tests     bool
 	TestsOnly bool
 
+	// Only, if set, only builds the files within the given top-level
+	// "NN-" directory or file of Root, such as "01-schema". See Steps.
+	Only string
+
 	// Metadata is added at the beginning of the file in a SQL comment.
 	Metadata map[string]string
 
@@ -69,7 +74,157 @@ func Build(cfg *Config) error {
 	// error reading files or if there is no output at all.
 	wroteHeader := false
 
-	return fs.WalkDir(cfg.FS, cfg.Root,
+	return walk(cfg.FS, cfg.Root, L, func(p string, log hclog.Logger) error {
+		_, file := filepath.Split(p)
+
+		// If we're only building a single step, ignore everything else.
+		if cfg.Only != "" && step(cfg.Root, p) != cfg.Only {
+			log.Trace("ignoring file outside of step", "only", cfg.Only)
+			return nil
+		}
+
+		// Plan, snapshot, fixture, setup, and roles files are not part of
+		// the schema and are never built.
+		for _, suffix := range nonSchemaSuffixes {
+			if strings.HasSuffix(file, suffix) {
+				log.Trace("ignoring non-schema file")
+				return nil
+			}
+		}
+
+		isTest := strings.HasSuffix(file, "_test.sql")
+
+		// Not a test, and we only want tests
+		if cfg.TestsOnly && !isTest {
+			log.Trace("ignoring non-test file in test only mode")
+			return nil
+		}
+
+		// If we're not included tests, skip this.
+		if !cfg.Tests && isTest {
+			log.Trace("skipping test file")
+			return nil
+		}
+
+		// SQL file, read and append it to our writer.
+		f, err := cfg.FS.Open(p)
+		if err != nil {
+			log.Warn("error reading file", "err", err)
+			return err
+		}
+		defer f.Close()
+
+		// Write our header for the whole file
+		if !wroteHeader {
+			// Write our first header
+			_, err := fmt.Fprintf(cfg.Output, header)
+			if err != nil {
+				return err
+			}
+
+			// Write our metadata
+			var keys []string
+			for k := range cfg.Metadata {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				v := cfg.Metadata[k]
+				_, err := fmt.Fprintf(cfg.Output, "-- %s: %s\n", k, v)
+				if err != nil {
+					return err
+				}
+			}
+
+			wroteHeader = true
+		}
+
+		// Write our filename so its easier to find merged content.
+		if _, err := fmt.Fprintf(cfg.Output, flowerBox, p); err != nil {
+			log.Warn("error writing file header", "err", err)
+			return err
+		}
+
+		// Append
+		if _, err := io.Copy(cfg.Output, f); err != nil {
+			log.Warn("error copying file", "err", err)
+			return err
+		}
+
+		log.Trace("added to output")
+		return nil
+	})
+}
+
+// Files returns the paths to all the SQL files ending in suffix (such as
+// PlanSuffix) in the root of the given filesystem, in the order they would
+// be built. These files follow the same "NN-" rules as any other SQL file.
+func Files(fsys fs.FS, root, suffix string, L hclog.Logger) ([]string, error) {
+	if L == nil {
+		L = hclog.L()
+	}
+
+	var result []string
+	err := walk(fsys, root, L, func(p string, log hclog.Logger) error {
+		if strings.HasSuffix(p, suffix) {
+			result = append(result, p)
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+// Steps returns the top-level "NN-" directories and files in the root of
+// the given filesystem that contain SQL files, in the order they would be
+// built. Building each step in order with Config.Only results in the same
+// schema as building the root.
+func Steps(fsys fs.FS, root string, L hclog.Logger) ([]string, error) {
+	if L == nil {
+		L = hclog.L()
+	}
+
+	var result []string
+	err := walk(fsys, root, L, func(p string, log hclog.Logger) error {
+		// Ignore files that would never be built.
+		for _, suffix := range nonSchemaSuffixes {
+			if strings.HasSuffix(p, suffix) {
+				return nil
+			}
+		}
+
+		s := step(root, p)
+		if len(result) == 0 || result[len(result)-1] != s {
+			result = append(result, s)
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+// step returns the top-level entry of root that contains the path p.
+func step(root, p string) string {
+	rel := strings.TrimPrefix(p, root+"/")
+	if idx := strings.IndexByte(rel, '/'); idx >= 0 {
+		rel = rel[:idx]
+	}
+
+	return rel
+}
+
+// walk walks the SQL files in root in lexicographic order, calling f for
+// each SQL file that is within a "NN-" prefixed directory (or is a "NN-"
+// prefixed file).
+func walk(
+	fsys fs.FS,
+	root string,
+	L hclog.Logger,
+	f func(string, hclog.Logger) error,
+) error {
+	return fs.WalkDir(fsys, root,
 		func(p string, d fs.DirEntry, err error) error {
 			log := L.With("path", p)
 			log.Trace("walking")
@@ -89,7 +244,7 @@ func Build(cfg *Config) error {
 			// immediate child.
 			dir, file := filepath.Split(p)
 			dir = filepath.Clean(dir)
-			child := dir == cfg.Root
+			child := dir == root
 			log.Trace("dir and file split", "dir", dir, "file", file)
 
 			// If we are a child, let's verify we care about this.
@@ -118,72 +273,43 @@ func Build(cfg *Config) error {
 				return nil
 			}
 
-			isTest := strings.HasSuffix(file, "_test.sql")
-
-			// Not a test, and we only want tests
-			if cfg.TestsOnly && !isTest {
-				log.Trace("ignoring non-test file in test only mode")
-				return nil
-			}
-
-			// If we're not included tests, skip this.
-			if !cfg.Tests && isTest {
-				log.Trace("skipping test file")
-				return nil
-			}
+			return f(p, log)
+		})
+}
 
-			// SQL file, read and append it to our writer.
-			f, err := cfg.FS.Open(p)
-			if err != nil {
-				log.Warn("error reading file", "err", err)
-				return err
-			}
-			defer f.Close()
+var reNumPrefix = regexp.MustCompile(`^\d\d-`)
 
-			// Write our header for the whole file
-			if !wroteHeader {
-				// Write our first header
-				_, err := fmt.Fprintf(cfg.Output, header)
-				if err != nil {
-					return err
-				}
+const (
+	// PlanSuffix is the suffix of query plan test files.
+	PlanSuffix = "_plan.sql"
 
-				// Write our metadata
-				var keys []string
-				for k := range cfg.Metadata {
-					keys = append(keys, k)
-				}
-				sort.Strings(keys)
-				for _, k := range keys {
-					v := cfg.Metadata[k]
-					_, err := fmt.Fprintf(cfg.Output, "-- %s: %s\n", k, v)
-					if err != nil {
-						return err
-					}
-				}
+	// SnapshotSuffix is the suffix of query result snapshot test files.
+	SnapshotSuffix = "_snapshot.sql"
 
-				wroteHeader = true
-			}
+	// FixtureSuffix is the suffix of test fixture files that are loaded
+	// into the test database after the schema.
+	FixtureSuffix = "_fixture.sql"
 
-			// Write our filename so its easier to find merged content.
-			if _, err := fmt.Fprintf(cfg.Output, flowerBox, p); err != nil {
-				log.Warn("error writing file header", "err", err)
-				return err
-			}
+	// SetupSuffix is the suffix of test setup files that are run prior
+	// to each test in the same "NN-" directory.
+	SetupSuffix = "_setup.sql"
 
-			// Append
-			if _, err := io.Copy(cfg.Output, f); err != nil {
-				log.Warn("error copying file", "err", err)
-				return err
-			}
+	// RolesSuffix is the suffix of files that create the roles used by
+	// tests. These are run in the test database before the schema.
+	RolesSuffix = "_roles.sql"
+)
 
-			log.Trace("added to output")
-			return nil
-		})
+// nonSchemaSuffixes are the suffixes of files that are used for testing
+// but are not part of the schema. These are never included in the built
+// schema.
+var nonSchemaSuffixes = []string{
+	PlanSuffix,
+	SnapshotSuffix,
+	FixtureSuffix,
+	SetupSuffix,
+	RolesSuffix,
 }
 
-var reNumPrefix = regexp.MustCompile(`^\d\d-`)
-
 const (
 	flowerBox = `
 ---------------------------------------------------------------------
@@ -82,3 +82,46 @@ func TestBuild_noExist(t *testing.T) {
 	// On error, we should not output anything
 	require.Empty(t, buf.String())
 }
+
+func TestFiles(t *testing.T) {
+	files, err := Files(os.DirFS("testdata"), "build", PlanSuffix, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"build/02-other/end_plan.sql"}, files)
+
+	files, err = Files(os.DirFS("testdata"), "build", SnapshotSuffix, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"build/02-other/end_snapshot.sql"}, files)
+}
+
+func TestSteps(t *testing.T) {
+	steps, err := Steps(os.DirFS("testdata"), "build", nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"00-schema", "01-", "02-other"}, steps)
+
+	// Building each step should be equivalent to building everything
+	var all bytes.Buffer
+	require.NoError(t, Build(&Config{
+		Output: &all,
+		FS:     os.DirFS("testdata"),
+		Root:   "build",
+	}))
+
+	var combined []byte
+	for i, s := range steps {
+		var buf bytes.Buffer
+		require.NoError(t, Build(&Config{
+			Output: &buf,
+			FS:     os.DirFS("testdata"),
+			Root:   "build",
+			Only:   s,
+		}))
+
+		// Strip the header from all but the first step
+		out := buf.Bytes()
+		if i > 0 {
+			out = bytes.TrimPrefix(out, []byte(header))
+		}
+		combined = append(combined, out...)
+	}
+	require.Equal(t, all.String(), string(combined))
+}
@@ -0,0 +1 @@
+SELECT * FROM foo WHERE id = 1;
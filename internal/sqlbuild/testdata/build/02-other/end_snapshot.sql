@@ -0,0 +1 @@
+SELECT * FROM foo ORDER BY id;
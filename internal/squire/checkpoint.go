@@ -0,0 +1,265 @@
+package squire
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"github.com/jackc/pgx/v4"
+
+	"github.com/mitchellh/squire/internal/dbcontainer"
+	"github.com/mitchellh/squire/internal/sqlbuild"
+)
+
+// checkpointStep is a single step of an incremental reset. Each step is
+// a top-level "NN-" directory or file in the SQL directory.
+type checkpointStep struct {
+	// Step is the name of the step, i.e. "01-schema".
+	Step string
+
+	// SQL is the built SQL for only this step.
+	SQL []byte
+
+	// Checkpoint is the name of the template database that has the
+	// schema applied up to and including this step.
+	Checkpoint string
+}
+
+// resetIncremental resets the database in the given container using
+// checkpoint databases. See ResetOptions.Incremental.
+func (s *Squire) resetIncremental(ctx context.Context, ctr dbcontainer.Container) error {
+	L := s.logger.Named("reset")
+
+	admin, dbname, err := adminDB(ctx, ctr.ConnURI())
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+
+	steps, err := s.checkpointSteps(dbname)
+	if err != nil {
+		return err
+	}
+
+	// Find all the checkpoints that exist for this database
+	existing, err := checkpointsExisting(ctx, admin, dbname)
+	if err != nil {
+		return err
+	}
+
+	// Find the last checkpoint that is still valid. Since each checkpoint
+	// name includes the hash of all prior steps, any existing checkpoint is
+	// valid.
+	start, template := 0, ""
+	for i := len(steps) - 1; i >= 0; i-- {
+		if _, ok := existing[steps[i].Checkpoint]; ok {
+			start, template = i+1, steps[i].Checkpoint
+			break
+		}
+	}
+	L.Debug("recreating the logical database",
+		"template", template, "skipped_steps", start, "total_steps", len(steps))
+	if err := recreateDBFrom(ctx, admin, dbname, template); err != nil {
+		L.Error("error recreating the db", "err", err)
+		return err
+	}
+
+	// Apply the remaining steps, checkpointing after each
+	valid := map[string]struct{}{}
+	for i, step := range steps {
+		valid[step.Checkpoint] = struct{}{}
+		if i < start {
+			continue
+		}
+
+		L := L.With("step", step.Step)
+		if len(step.SQL) > 0 {
+			L.Debug("deploying step")
+			if err := s.deployStep(ctx, ctr, step.SQL); err != nil {
+				return fmt.Errorf("error applying %s: %w", step.Step, err)
+			}
+		}
+
+		L.Debug("creating checkpoint", "checkpoint", step.Checkpoint)
+		if err := createCheckpoint(ctx, admin, dbname, step.Checkpoint); err != nil {
+			return err
+		}
+	}
+
+	// Remove checkpoints that are no longer valid so that they don't
+	// accumulate forever.
+	for name := range existing {
+		if _, ok := valid[name]; ok {
+			continue
+		}
+
+		L.Debug("removing stale checkpoint", "checkpoint", name)
+		if _, err := admin.ExecContext(ctx,
+			"DROP DATABASE IF EXISTS "+pgx.Identifier{name}.Sanitize()+" WITH (FORCE)"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkpointSteps builds the SQL for every step of an incremental reset
+// of the database dbname.
+func (s *Squire) checkpointSteps(dbname string) ([]*checkpointStep, error) {
+	rootDir, rootFile, err := s.sqlRoot()
+	if err != nil {
+		return nil, err
+	}
+
+	fsys := os.DirFS(rootDir)
+	L := s.logger.Named("sqlbuild")
+	names, err := sqlbuild.Steps(fsys, rootFile, L)
+	if err != nil {
+		return nil, err
+	}
+
+	steps := make([]*checkpointStep, len(names))
+	for i, name := range names {
+		// No metadata so that the output is deterministic
+		var buf bytes.Buffer
+		if err := sqlbuild.Build(&sqlbuild.Config{
+			Output: &buf,
+			FS:     fsys,
+			Root:   rootFile,
+			Only:   name,
+			Logger: L,
+		}); err != nil {
+			return nil, err
+		}
+
+		steps[i] = &checkpointStep{Step: name, SQL: buf.Bytes()}
+	}
+
+	steps = mergeSessionSteps(steps)
+	checkpointNames(dbname, steps)
+	return steps, nil
+}
+
+// mergeSessionSteps merges the first step that contains a top-level SET
+// or RESET statement with all the steps after it. Each step is deployed
+// on its own connection, so a session setting such as "SET search_path"
+// would otherwise not apply to the later steps like it does when the full
+// schema is deployed on one connection.
+func mergeSessionSteps(steps []*checkpointStep) []*checkpointStep {
+	// The last step has no steps after it, so a setting in it is fine.
+	for i := 0; i < len(steps)-1; i++ {
+		if !hasSessionSetting(steps[i].SQL) {
+			continue
+		}
+
+		merged := &checkpointStep{Step: steps[i].Step}
+		for _, rest := range steps[i:] {
+			merged.SQL = append(merged.SQL, rest.SQL...)
+			merged.SQL = append(merged.SQL, '\n')
+		}
+
+		return append(steps[:i:i], merged)
+	}
+
+	return steps
+}
+
+// hasSessionSetting returns true if the SQL contains a top-level SET or
+// RESET statement.
+func hasSessionSetting(src []byte) bool {
+	for _, stmt := range splitStatements(string(src)) {
+		if reSettingStatement.MatchString(stmt) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// deployStep deploys the SQL for a single step to the database in the
+// container. The connection is closed when this returns so that the
+// database can be used as a template.
+func (s *Squire) deployStep(ctx context.Context, ctr dbcontainer.Container, src []byte) error {
+	db, err := ctr.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return s.Deploy(ctx, &DeployOptions{
+		SQL:    bytes.NewReader(src),
+		Target: db,
+	})
+}
+
+// checkpointNames sets the checkpoint name on every step. The name is
+// based on the hash of the SQL of the step and all steps prior to it, so
+// a change to any step invalidates the checkpoints for it and all later
+// steps.
+func checkpointNames(dbname string, steps []*checkpointStep) {
+	h := sha256.New()
+	for i, step := range steps {
+		fmt.Fprintf(h, "%s\x00%d\x00", step.Step, len(step.SQL))
+		h.Write(step.SQL)
+
+		sum := hex.EncodeToString(h.Sum(nil))
+		step.Checkpoint = fmt.Sprintf("%s%02d_%s",
+			dbcontainer.CheckpointPrefix(dbname), i, sum[:12])
+	}
+}
+
+// checkpointsExisting returns the names of all the existing checkpoint
+// databases for dbname.
+func checkpointsExisting(ctx context.Context, db *sql.DB, dbname string) (map[string]struct{}, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT datname FROM pg_catalog.pg_database WHERE starts_with(datname, $1)",
+		dbcontainer.CheckpointPrefix(dbname))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := map[string]struct{}{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+
+		result[name] = struct{}{}
+	}
+
+	return result, rows.Err()
+}
+
+// createCheckpoint creates the checkpoint database name as a copy of the
+// database dbname. A database can't be copied while there are other
+// connections to it, so any other connections are terminated.
+func createCheckpoint(ctx context.Context, db *sql.DB, dbname, name string) error {
+	if _, err := db.ExecContext(ctx, queryTerminateBackends, dbname); err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(ctx,
+		"DROP DATABASE IF EXISTS "+pgx.Identifier{name}.Sanitize()+" WITH (FORCE)"); err != nil {
+		return err
+	}
+
+	_, err := db.ExecContext(ctx, "CREATE DATABASE "+pgx.Identifier{name}.Sanitize()+
+		" TEMPLATE "+pgx.Identifier{dbname}.Sanitize())
+	return err
+}
+
+const (
+	// queryTerminateBackends terminates all connections to the database
+	// given as the first parameter, other than our own.
+	queryTerminateBackends = `
+SELECT pg_catalog.pg_terminate_backend(pid)
+FROM pg_catalog.pg_stat_activity
+WHERE datname = $1 AND pid <> pg_catalog.pg_backend_pid()
+`
+)
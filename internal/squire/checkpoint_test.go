@@ -0,0 +1,135 @@
+package squire
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mitchellh/squire/internal/config"
+	"github.com/mitchellh/squire/internal/dbcontainer"
+)
+
+func TestCheckpointNames(t *testing.T) {
+	require := require.New(t)
+
+	build := func(sqls ...string) []*checkpointStep {
+		var steps []*checkpointStep
+		for i, sql := range sqls {
+			steps = append(steps, &checkpointStep{
+				Step: []string{"00-a", "01-b", "02-c"}[i],
+				SQL:  []byte(sql),
+			})
+		}
+
+		checkpointNames("squire", steps)
+		return steps
+	}
+
+	a := build("A", "B", "C")
+	for _, s := range a {
+		require.True(strings.HasPrefix(s.Checkpoint, dbcontainer.CheckpointPrefix("squire")))
+		require.LessOrEqual(len(s.Checkpoint), 63)
+	}
+
+	// Deterministic
+	require.Equal(a, build("A", "B", "C"))
+
+	// Changing a step invalidates it and every step after it
+	b := build("A", "X", "C")
+	require.Equal(a[0].Checkpoint, b[0].Checkpoint)
+	require.NotEqual(a[1].Checkpoint, b[1].Checkpoint)
+	require.NotEqual(a[2].Checkpoint, b[2].Checkpoint)
+
+	// Moving SQL between steps changes the checkpoints
+	c := build("AB", "", "C")
+	require.NotEqual(a[0].Checkpoint, c[0].Checkpoint)
+	require.NotEqual(a[2].Checkpoint, c[2].Checkpoint)
+}
+
+func TestMergeSessionSteps(t *testing.T) {
+	require := require.New(t)
+
+	build := func(sqls ...string) []*checkpointStep {
+		var steps []*checkpointStep
+		for i, sql := range sqls {
+			steps = append(steps, &checkpointStep{
+				Step: []string{"00-a", "01-b", "02-c"}[i],
+				SQL:  []byte(sql),
+			})
+		}
+
+		return mergeSessionSteps(steps)
+	}
+
+	// No settings, nothing is merged
+	require.Len(build("CREATE SCHEMA a;", "CREATE TABLE t ();", "SELECT 1;"), 3)
+
+	// A setting in the last step has nothing after it
+	require.Len(build("A;", "B;", "SET search_path = a;"), 3)
+
+	// A setting merges the step with every step after it
+	steps := build("A;", "-- comment\nSET search_path = a;", "CREATE TABLE t ();")
+	require.Len(steps, 2)
+	require.Equal("01-b", steps[1].Step)
+	require.Contains(string(steps[1].SQL), "CREATE TABLE t ()")
+
+	// Settings within strings and functions are ignored
+	require.Len(build("SELECT 'SET x = 1;';",
+		"CREATE FUNCTION f() RETURNS void AS $$ SET x = 1; $$ LANGUAGE sql;",
+		"C;"), 3)
+}
+
+func TestCheckpointSteps_sessionSetting(t *testing.T) {
+	require := require.New(t)
+
+	cfg, err := config.New(config.FromString(
+		`sql_dir: "testdata/checkpoint"`))
+	require.NoError(err)
+	sq, err := New(WithConfig(cfg))
+	require.NoError(err)
+
+	// 01-tables depends on the search_path set in 00-schema, so they
+	// must be deployed together.
+	steps, err := sq.checkpointSteps("squire")
+	require.NoError(err)
+	require.Len(steps, 1)
+	require.Equal("00-schema", steps[0].Step)
+	require.Contains(string(steps[0].SQL), "SET search_path")
+	require.Contains(string(steps[0].SQL), "CREATE TABLE things")
+	require.Contains(string(steps[0].SQL), "INSERT INTO things")
+}
+
+func TestReset_incrementalSessionSetting(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	cfg, err := config.New(config.FromString(
+		`sql_dir: "testdata/checkpoint"`))
+	require.NoError(err)
+	sq, err := New(WithConfig(cfg))
+	require.NoError(err)
+
+	ctr, err := sq.Container()
+	require.NoError(err)
+	require.NoError(ctr.Up(ctx))
+	defer ctr.Down(ctx)
+
+	// Reset twice so the second reset starts from a checkpoint
+	for i := 0; i < 2; i++ {
+		require.NoError(sq.Reset(ctx, &ResetOptions{
+			Container:   ctr,
+			Incremental: true,
+		}))
+	}
+
+	db, err := ctr.Conn(ctx)
+	require.NoError(err)
+	defer db.Close()
+
+	var count int
+	require.NoError(db.QueryRowContext(ctx,
+		"SELECT count(*) FROM app.things").Scan(&count))
+	require.Equal(1, count)
+}
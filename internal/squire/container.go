@@ -1,7 +1,8 @@
 package squire
 
 import (
-	"github.com/mitchellh/squire/internal/config"
+	"github.com/compose-spec/compose-go/types"
+
 	"github.com/mitchellh/squire/internal/dbcompose"
 	"github.com/mitchellh/squire/internal/dbcontainer"
 	"github.com/mitchellh/squire/internal/dbdefault"
@@ -9,29 +10,98 @@ import (
 
 // Container returns the primary dev container for this instance. The
 // container instance can then be further used to get access to clones.
-func (s *Squire) Container() (*dbcontainer.Container, error) {
-	// Look for a docker compose yaml file in parent directories
-	composePath, err := config.FindPath("", "docker-compose.yml")
+// The container runs with the backend configured by "dev.backend".
+func (s *Squire) Container() (dbcontainer.Container, error) {
+	switch s.config.Dev.Backend {
+	case backendLocal:
+		return s.localContainer()
+	case backendExternal:
+		return s.externalContainer()
+	}
+
+	// Build our config. The default project is used if there is no
+	// docker compose file.
+	opts := []dbcompose.Option{
+		dbcompose.WithLogger(s.logger.Named("compose")),
+		dbcompose.WithDefaultFunc(func() (*types.Project, error) {
+			return dbdefault.Project(s.config)
+		}),
+		dbcompose.WithDiscover(""),
+		dbcompose.WithService(s.service),
+	}
+	if v := s.config.Dev.FastClones; v != nil {
+		opts = append(opts, dbcompose.WithFastClones(*v))
+	}
+
+	cfg, err := dbcompose.New(opts...)
 	if err != nil {
 		return nil, err
 	}
-	if composePath == "" {
-		composePath = "docker-compose.yml"
+
+	// Build our container
+	return dbcontainer.New(
+		dbcontainer.WithLogger(s.logger.Named("container")),
+		dbcontainer.WithCompose(cfg),
+	)
+}
+
+// localContainer returns the primary dev container for the "local"
+// backend. Each database of a project with multiple databases is a
+// separate cluster.
+func (s *Squire) localContainer() (dbcontainer.Container, error) {
+	cfg, err := dbdefault.Local(s.config, s.database)
+	if err != nil {
+		return nil, err
 	}
 
-	// Build our config
-	cfg, err := dbcompose.New(
-		dbcompose.WithLogger(s.logger.Named("compose")),
-		dbcompose.WithDefault(dbdefault.Project()),
-		dbcompose.WithPath(composePath),
+	return dbcontainer.New(
+		dbcontainer.WithLogger(s.logger.Named("container")),
+		dbcontainer.WithLocal(cfg),
 	)
+}
+
+// externalContainer returns the primary dev container for the "external"
+// backend, which is a database on an existing server.
+func (s *Squire) externalContainer() (dbcontainer.Container, error) {
+	cfg, err := dbdefault.External(s.config, s.database)
 	if err != nil {
 		return nil, err
 	}
 
-	// Build our container
 	return dbcontainer.New(
 		dbcontainer.WithLogger(s.logger.Named("container")),
-		dbcontainer.WithCompose(cfg),
+		dbcontainer.WithExternal(cfg),
 	)
 }
+
+// ComposeFiles returns the Docker Compose files that configure the dev
+// container, in the order they are merged. This is empty if there are
+// none and the default container is used, or if Docker isn't used by
+// the backend. See dbcompose.Discover.
+func (s *Squire) ComposeFiles() ([]string, error) {
+	if s.config.Dev.Backend != backendDocker {
+		return nil, nil
+	}
+
+	return dbcompose.Discover("")
+}
+
+const (
+	// These are the values of the "dev.backend" setting.
+	backendDocker   = "docker"
+	backendLocal    = "local"
+	backendExternal = "external"
+)
+
+// Pool returns the pool of clones of the primary dev container that is
+// used for diffs and tests. The size of the pool is configured with
+// "dev.pool_size" and may be zero, in which case the pool is disabled
+// but can still be drained.
+func (s *Squire) Pool() (*dbcontainer.Pool, error) {
+	ctr, err := s.Container()
+	if err != nil {
+		return nil, err
+	}
+
+	return ctr.Pool(s.config.Dev.PoolSize), nil
+}
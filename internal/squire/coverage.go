@@ -0,0 +1,218 @@
+package squire
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/cockroachdb/errors"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/mitchellh/squire/internal/dbcontainer"
+)
+
+// FunctionCoverage is the test coverage information for a single function
+// or procedure in the schema.
+type FunctionCoverage struct {
+	// Schema and Name are the schema and name of the function. Signature
+	// is the full signature including argument types, which is useful to
+	// differentiate overloaded functions.
+	Schema    string
+	Name      string
+	Signature string
+
+	// File is the path to the SQL file that defines this function,
+	// relative to the parent of the SQL directory. This may be empty if
+	// the function was not created directly by a SQL file (for example if
+	// it was created dynamically).
+	File string
+
+	// Calls is the number of times the function was called during the
+	// test run. TotalTime is the total time spent in the function
+	// including other functions it called.
+	Calls     int64
+	TotalTime time.Duration
+}
+
+// enableCoverage enables function call tracking for the database in the
+// given container. This only affects new sessions, so this should be called
+// prior to connecting for the test run.
+func enableCoverage(ctx context.Context, ctr dbcontainer.Container) error {
+	db, err := ctr.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var dbname string
+	if err := db.QueryRowContext(ctx, "select current_database()").Scan(&dbname); err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, "ALTER DATABASE "+pgx.Identifier{dbname}.Sanitize()+" SET track_functions = 'all'")
+	return err
+}
+
+// readCoverage reads the function call statistics for all user-defined
+// functions in the database. The schema is the built SQL schema (with
+// tests) and is used to map functions back to the file that defined them.
+// Functions defined in test files are not included.
+func readCoverage(ctx context.Context, db *sql.DB, schema []byte) ([]*FunctionCoverage, error) {
+	files, err := functionFiles(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	// Statistics are sent to the stats collector asynchronously when
+	// each test backend exits, so we read until the results stabilize.
+	var result []*FunctionCoverage
+	err = backoff.Retry(func() error {
+		next, err := queryCoverage(ctx, db)
+		if err != nil {
+			return backoff.Permanent(err)
+		}
+
+		prev := result
+		result = next
+		if prev == nil || !reflect.DeepEqual(coverageCalls(prev), coverageCalls(next)) {
+			return errCoverageUnstable
+		}
+
+		return nil
+	}, backoff.WithContext(
+		backoff.WithMaxRetries(backoff.NewConstantBackOff(250*time.Millisecond), 8),
+		ctx,
+	))
+	if err != nil && !errors.Is(err, errCoverageUnstable) {
+		return nil, err
+	}
+
+	// Map our functions back to files and filter out test functions.
+	final := make([]*FunctionCoverage, 0, len(result))
+	for _, fc := range result {
+		fc.File = files[fc.Schema+"."+fc.Name]
+		if fc.File == "" {
+			fc.File = files[fc.Name]
+		}
+		if strings.HasSuffix(fc.File, "_test.sql") {
+			continue
+		}
+
+		final = append(final, fc)
+	}
+
+	return final, nil
+}
+
+// queryCoverage queries the current function statistics.
+func queryCoverage(ctx context.Context, db *sql.DB) ([]*FunctionCoverage, error) {
+	rows, err := db.QueryContext(ctx, queryFunctionCoverage)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*FunctionCoverage
+	for rows.Next() {
+		var fc FunctionCoverage
+		var totalMs float64
+		if err := rows.Scan(
+			&fc.Schema, &fc.Name, &fc.Signature, &fc.Calls, &totalMs,
+		); err != nil {
+			return nil, err
+		}
+
+		fc.TotalTime = time.Duration(totalMs * float64(time.Millisecond))
+		result = append(result, &fc)
+	}
+
+	return result, rows.Err()
+}
+
+// coverageCalls returns only the call counts so that two coverage reads
+// can be compared.
+func coverageCalls(v []*FunctionCoverage) []int64 {
+	result := make([]int64, len(v))
+	for i, fc := range v {
+		result[i] = fc.Calls
+	}
+
+	return result
+}
+
+// functionFiles parses the built schema and returns a mapping of function
+// name to the file that defines it. Functions that specify a schema are
+// keyed by "schema.name", otherwise they're keyed by "name". Names are
+// lowercased unless they're quoted, to match PostgreSQL.
+//
+// This relies on the file headers written by sqlbuild. This is a best-effort
+// mapping, it is not a full SQL parser.
+func functionFiles(schema []byte) (map[string]string, error) {
+	result := map[string]string{}
+
+	var file string
+	scanner := bufio.NewScanner(bytes.NewReader(schema))
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := reFileHeader.FindStringSubmatch(line); m != nil {
+			file = m[1]
+			continue
+		}
+
+		for _, m := range reCreateFunction.FindAllStringSubmatch(line, -1) {
+			var parts []string
+			for _, part := range strings.Split(m[1], ".") {
+				if strings.HasPrefix(part, `"`) {
+					part = strings.Trim(part, `"`)
+				} else {
+					part = strings.ToLower(part)
+				}
+
+				parts = append(parts, part)
+			}
+
+			result[strings.Join(parts, ".")] = file
+		}
+	}
+
+	return result, scanner.Err()
+}
+
+var (
+	reFileHeader     = regexp.MustCompile(`^-- File: (.+)$`)
+	reCreateFunction = regexp.MustCompile(
+		`(?i)create\s+(?:or\s+replace\s+)?(?:function|procedure)\s+((?:"[^"]+"|[\w$]+)(?:\.(?:"[^"]+"|[\w$]+))?)\s*\(`)
+
+	errCoverageUnstable = errors.New("coverage statistics did not stabilize")
+)
+
+const (
+	// queryFunctionCoverage lists all user-defined functions and procedures
+	// along with their call statistics. Functions owned by extensions and
+	// pgUnit or the Squire test runtime are ignored.
+	queryFunctionCoverage = `
+SELECT n.nspname, p.proname, p.oid::regprocedure::text,
+       COALESCE(s.calls, 0), COALESCE(s.total_time, 0)
+FROM pg_catalog.pg_proc p
+JOIN pg_catalog.pg_namespace n ON n.oid = p.pronamespace
+LEFT JOIN pg_catalog.pg_stat_user_functions s ON s.funcid = p.oid
+WHERE n.nspname NOT IN ('pg_catalog', 'information_schema', 'pgunit', 'squire_test')
+  AND n.nspname NOT LIKE 'pg_toast%'
+  AND n.nspname NOT LIKE 'pg_temp%'
+  AND p.prokind IN ('f', 'p')
+  AND NOT EXISTS (
+    SELECT 1 FROM pg_catalog.pg_depend d
+    WHERE d.classid = 'pg_catalog.pg_proc'::regclass
+      AND d.objid = p.oid
+      AND d.deptype = 'e'
+  )
+ORDER BY n.nspname, p.proname, 3
+`
+)
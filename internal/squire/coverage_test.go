@@ -0,0 +1,35 @@
+package squire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFunctionFiles(t *testing.T) {
+	require := require.New(t)
+
+	schema := []byte(`-- This file is auto-generated. DO NOT EDIT.
+
+---------------------------------------------------------------------
+-- File: sql/01-functions/func.sql
+---------------------------------------------------------------------
+CREATE OR REPLACE FUNCTION Account_With_Org(_id INTEGER)
+RETURNS VOID AS $$ BEGIN END; $$ LANGUAGE plpgsql;
+
+create procedure app."DoThing"() language sql as $$ select 1 $$;
+
+---------------------------------------------------------------------
+-- File: sql/01-functions/func_test.sql
+---------------------------------------------------------------------
+CREATE FUNCTION test_case_account_with_org() RETURNS VOID AS $$ BEGIN END; $$ LANGUAGE plpgsql;
+`)
+
+	files, err := functionFiles(schema)
+	require.NoError(err)
+	require.Equal(map[string]string{
+		"account_with_org":           "sql/01-functions/func.sql",
+		"app.DoThing":                "sql/01-functions/func.sql",
+		"test_case_account_with_org": "sql/01-functions/func_test.sql",
+	}, files)
+}
@@ -0,0 +1,62 @@
+package squire
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Databases returns the names of the databases configured with the
+// "databases" setting, sorted. This is empty if the project has a single
+// database, in which case this Squire instance is used directly.
+func (s *Squire) Databases() []string {
+	result := make([]string, 0, len(s.config.Databases))
+	for name := range s.config.Databases {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+// Database returns a Squire instance for the named database from the
+// "databases" setting. The returned instance uses the SQL directory and
+// Docker Compose service of that database for all operations.
+func (s *Squire) Database(name string) (*Squire, error) {
+	db, ok := s.config.Databases[name]
+	if !ok {
+		return nil, errors.WithDetailf(
+			errors.Newf("unknown database %q", name),
+			strings.TrimSpace(errDetailUnknownDatabase),
+			strings.Join(s.Databases(), ", "),
+		)
+	}
+
+	cfg := *s.config
+	cfg.SQLDir = db.SQLDir
+
+	return &Squire{
+		logger:   s.logger.With("db", name),
+		config:   &cfg,
+		database: name,
+		service:  db.Service,
+	}, nil
+}
+
+// DatabaseName returns the name of the database this instance is for,
+// from the "databases" setting. This is empty if the project has a single
+// database. See Database.
+func (s *Squire) DatabaseName() string {
+	return s.database
+}
+
+const (
+	errDetailUnknownDatabase = `
+Databases are configured with the "databases" setting of your Squire
+configuration. The configured databases are listed below. If this is empty,
+the project has a single database and "-db" should not be set.
+
+Databases: %s
+`
+)
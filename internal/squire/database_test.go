@@ -0,0 +1,40 @@
+package squire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mitchellh/squire/internal/config"
+)
+
+func TestDatabase(t *testing.T) {
+	require := require.New(t)
+
+	cfg, err := config.New(config.FromString(`
+databases: {
+	oltp: {}
+	reporting: service: "warehouse"
+}
+`))
+	require.NoError(err)
+
+	sq, err := New(WithConfig(cfg))
+	require.NoError(err)
+	require.Equal([]string{"oltp", "reporting"}, sq.Databases())
+	require.Empty(sq.DatabaseName())
+
+	// Select a database
+	db, err := sq.Database("reporting")
+	require.NoError(err)
+	require.Equal("reporting", db.DatabaseName())
+	require.Equal("warehouse", db.service)
+	require.Equal("sql/reporting", db.config.SQLDir)
+
+	// The original is unchanged
+	require.Equal("sql", sq.config.SQLDir)
+
+	// Unknown database
+	_, err = sq.Database("nope")
+	require.Error(err)
+}
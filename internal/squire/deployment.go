@@ -0,0 +1,165 @@
+package squire
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mitchellh/squire/internal/pkg/cachedir"
+)
+
+// Deployment is the record of the last deployment to production, which is
+// used to detect changes made to production outside of Squire. See
+// RecordDeployment.
+type Deployment struct {
+	// Time is when the deployment completed.
+	Time time.Time `json:"time"`
+
+	// SchemaHash is the hash of the production schema after the deployment,
+	// see schemaHash.
+	SchemaHash string `json:"schema_hash"`
+}
+
+// RecordDeployment records that the database at uri, which should be
+// production, was just deployed to. Its schema is dumped so that later
+// changes to it can be detected, see Drifted.
+//
+// Deployments are recorded per user in the Squire cache directory, so only
+// deployments made from this machine are known.
+func (s *Squire) RecordDeployment(ctx context.Context, uri string) error {
+	hash, err := s.schemaHash(ctx, uri)
+	if err != nil {
+		return err
+	}
+
+	bs, err := json.MarshalIndent(&Deployment{
+		Time:       time.Now().UTC(),
+		SchemaHash: hash,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path, err := s.deploymentPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, bs, 0644)
+}
+
+// LastDeployment returns the last deployment recorded with
+// RecordDeployment, or nil if there is none.
+func (s *Squire) LastDeployment() (*Deployment, error) {
+	path, err := s.deploymentPath()
+	if err != nil {
+		return nil, err
+	}
+
+	bs, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var result Deployment
+	if err := json.Unmarshal(bs, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// Drifted returns true if the schema of the database at uri has changed
+// since the given deployment.
+func (s *Squire) Drifted(ctx context.Context, uri string, d *Deployment) (bool, error) {
+	hash, err := s.schemaHash(ctx, uri)
+	if err != nil {
+		return false, err
+	}
+
+	return hash != d.SchemaHash, nil
+}
+
+// deploymentPath returns the path to the deployment record of this
+// database.
+func (s *Squire) deploymentPath() (string, error) {
+	path, err := cachedir.Project("deployments")
+	if err != nil {
+		return "", err
+	}
+	if s.database != "" {
+		path += "-" + s.database
+	}
+
+	return path + ".json", nil
+}
+
+// schemaHash returns a hash of the schema-only dump of the database at
+// uri. Only the statements that define the schema are hashed (see
+// schemaStatements) so that the comments and session settings that differ
+// between versions of pg_dump don't change the hash. A different version
+// of pg_dump can still format the same schema differently, so the same
+// version should be used for RecordDeployment and Drifted.
+func (s *Squire) schemaHash(ctx context.Context, uri string) (string, error) {
+	var buf bytes.Buffer
+	if err := s.Dump(ctx, &DumpOptions{
+		TargetURI: uri,
+		Output:    &buf,
+	}); err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(schemaStatements(buf.Bytes()))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// schemaStatements returns the SQL statements of a dump (see sqlStatements)
+// without the statements that configure the session for the restore. These
+// are at the start of every dump and are added to with new versions of
+// pg_dump, i.e. "SET transaction_timeout = 0;" in 17.
+func schemaStatements(src []byte) []byte {
+	var result bytes.Buffer
+	for _, line := range bytes.SplitAfter(sqlStatements(src), []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("SET ")) ||
+			bytes.HasPrefix(line, []byte("SELECT pg_catalog.set_config(")) {
+			continue
+		}
+
+		result.Write(line)
+	}
+
+	return result.Bytes()
+}
+
+// sqlStatements returns the SQL of a dump or build with blank lines, SQL
+// comments, and psql meta-commands removed. Dumps include the version of
+// pg_dump in a comment and, in recent versions, a random key in the
+// "\restrict" meta-command, none of which is part of the schema.
+func sqlStatements(src []byte) []byte {
+	var result bytes.Buffer
+	for _, line := range bytes.Split(src, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 ||
+			bytes.HasPrefix(line, []byte("--")) ||
+			bytes.HasPrefix(line, []byte(`\`)) {
+			continue
+		}
+
+		result.Write(line)
+		result.WriteByte('\n')
+	}
+
+	return result.Bytes()
+}
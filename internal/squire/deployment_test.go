@@ -0,0 +1,89 @@
+package squire
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mitchellh/squire/internal/config"
+	"github.com/mitchellh/squire/internal/pkg/cachedir"
+)
+
+func TestLastDeployment(t *testing.T) {
+	require := require.New(t)
+	old := cachedir.Dir
+	dir := t.TempDir()
+	cachedir.Dir = func() (string, error) { return dir, nil }
+	t.Cleanup(func() { cachedir.Dir = old })
+
+	cfg, err := config.New(config.FromString(`databases: reporting: {}`))
+	require.NoError(err)
+	sq, err := New(WithConfig(cfg))
+	require.NoError(err)
+
+	// Nothing recorded
+	d, err := sq.LastDeployment()
+	require.NoError(err)
+	require.Nil(d)
+
+	path, err := sq.deploymentPath()
+	require.NoError(err)
+	bs, err := json.Marshal(&Deployment{SchemaHash: "abc"})
+	require.NoError(err)
+	require.NoError(os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(ioutil.WriteFile(path, bs, 0644))
+
+	d, err = sq.LastDeployment()
+	require.NoError(err)
+	require.Equal("abc", d.SchemaHash)
+
+	// Each database has its own record
+	db, err := sq.Database("reporting")
+	require.NoError(err)
+	d, err = db.LastDeployment()
+	require.NoError(err)
+	require.Nil(d)
+}
+
+func TestSQLStatements(t *testing.T) {
+	require := require.New(t)
+
+	dump := func(version, key string) []byte {
+		return []byte(`--
+-- PostgreSQL database dump
+--
+
+\restrict ` + key + `
+
+-- Dumped by pg_dump version ` + version + `
+
+SET statement_timeout = 0;
+
+CREATE TABLE public.users (
+    id integer NOT NULL
+);
+
+\unrestrict ` + key + `
+`)
+	}
+
+	require.Equal("SET statement_timeout = 0;\nCREATE TABLE public.users (\nid integer NOT NULL\n);\n",
+		string(sqlStatements(dump("13.4", "abc"))))
+	require.Equal(sqlStatements(dump("13.4", "abc")), sqlStatements(dump("17.6", "def")))
+
+	// Session settings differ between versions and aren't part of the schema
+	require.Equal("CREATE TABLE public.users (\nid integer NOT NULL\n);\n",
+		string(schemaStatements(dump("13.4", "abc"))))
+	require.Equal(schemaStatements(dump("13.4", "abc")),
+		schemaStatements(bytes.Replace(dump("17.6", "def"),
+			[]byte("SET statement_timeout = 0;"),
+			[]byte("SET statement_timeout = 0;\nSET transaction_timeout = 0;"), 1)))
+
+	// Only comments is no statements
+	require.Empty(sqlStatements([]byte("\n-- Generation Time: now\n\n")))
+}
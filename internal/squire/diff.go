@@ -6,13 +6,11 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
 	"os/exec"
 	"reflect"
 	"sort"
 	"strings"
-	"time"
 
 	"github.com/cockroachdb/errors"
 	"github.com/hexops/gotextdiff"
@@ -20,16 +18,17 @@ import (
 	"github.com/hexops/gotextdiff/span"
 
 	"github.com/mitchellh/squire/internal/dbcontainer"
-	"github.com/mitchellh/squire/internal/pkg/stdcapture"
 )
 
 type DiffOptions struct {
 	// Container is the primary dev container. If no target URI is specified,
 	// the primary dev container is used as the target. If a target URI is
-	// specified, then this is only used to create a temporary instance to
-	// compare against a clean schema. If this is nil, the default Container
-	// is used.
-	Container *dbcontainer.Container
+	// specified, then this is only used to create a temporary database to
+	// compare against a clean schema. The temporary database is a clone
+	// leased from the pool if "dev.pool_size" is set, a shadow database
+	// within this container if it is running, or otherwise a new clone of
+	// this container. If this is nil, the default Container is used.
+	Container dbcontainer.Container
 
 	// TargetURI is the PostgreSQL connection address with the current
 	// "live" schema. If this is empty, then the target will be the primary
@@ -93,41 +92,38 @@ func (s *Squire) Diff(ctx context.Context, opts *DiffOptions) error {
 		opts.TargetURI = opts.Container.ConnURI()
 	}
 
-	// We need to create a temporary container to reset onto for the
-	// diffing process.
-	L.Debug("cloning and launching source container")
-	source, err := opts.Container.Clone(fmt.Sprintf("diff-%d", time.Now().Unix()))
+	// We need a temporary database to reset onto for the diffing process.
+	// This is a clone leased from the pool if there is one, a shadow
+	// database within the dev container if it is running, and otherwise
+	// we launch a clone of the dev container.
+	source, err := s.scratchDBFor(ctx, opts.Container, "diff")
 	if err != nil {
 		return errors.WithDetail(
-			errors.Newf("error creating source container: %w", err),
+			errors.Newf("error creating source database: %w", err),
 			strings.TrimSpace(errCreatingSource),
 		)
 	}
 
-	// We need to capture stdout/stderr because the compose API doesn't
-	// allow configurable output streams.
-	err = stdcapture.SuccessOnly(ioutil.Discard, ioutil.Discard, func() error {
-		return source.Up(ctx)
-	})
-	if err != nil {
-		return errors.WithDetail(
-			errors.Newf("error starting source container: %w", err),
-			strings.TrimSpace(errCreatingSource),
-		)
-	}
+	// Destroy is deferred prior to Up so that a partially created source
+	// is cleaned up as well, i.e. if we're interrupted while starting.
 	defer func() {
-		err = stdcapture.SuccessOnly(ioutil.Discard, ioutil.Discard, func() error {
-			return source.Down(ctx)
-		})
-		if err != nil {
-			L.Error("error destroying source container, may still be dangling",
+		if err := source.Destroy(); err != nil {
+			L.Error("error destroying source database, may still be dangling",
 				"err", err)
 		}
 	}()
+	L.Debug("creating source database",
+		"shadow", source.shadow, "pool", source.lease != nil)
+	if err := source.Up(ctx); err != nil {
+		return errors.WithDetail(
+			errors.Newf("error creating source database: %w", err),
+			strings.TrimSpace(errCreatingSource),
+		)
+	}
 
 	// Reset on our source
 	if err := s.Reset(ctx, &ResetOptions{
-		Container: source,
+		Container: source.Container,
 	}); err != nil {
 		return errors.WithDetail(
 			errors.Newf("error applying schema to source container: %w", err),
@@ -207,7 +203,7 @@ func (s *Squire) Diff(ctx context.Context, opts *DiffOptions) error {
 	// Reset our temporary DB with the dump
 	L.Debug("resetting the source container with our target dump")
 	if err := s.Reset(ctx, &ResetOptions{
-		Container: source,
+		Container: source.Container,
 		Schema:    bytes.NewReader(dumpActual.Bytes()),
 	}); err != nil {
 		return errors.WithDetail(
@@ -329,12 +325,16 @@ for more help.
 `
 
 	errCreatingSource = `
-Squire creates a container clone to apply a clean version of your current
-schema in order to create the diff. We don't use the currently active
-dev container because it might have additional data or manual changes applied
+Squire creates a temporary database to apply a clean version of your current
+schema in order to create the diff. We don't use the currently active dev
+database because it might have additional data or manual changes applied
 or it might be the target database for the diff.
 
-The error above was received while attempting to start this source container
+If the dev container is running, the temporary database is created within it
+(named "squire_shadow_diff"). Otherwise, Squire starts a clone of the dev
+container.
+
+The error above was received while attempting to create this source database
 for diffing. Please resolve the error and try again.
 `
 
@@ -56,4 +56,14 @@ func TestDiff(t *testing.T) {
 	require.NoError(sq2.Diff(ctx, &DiffOptions{Output: &out, Verify: true}))
 	require.NotEmpty(out.String())
 	require.Equal(out1, out.String())
+
+	// The shadow database should be gone
+	db, err := ctr.Conn(ctx)
+	require.NoError(err)
+	defer db.Close()
+	var count int
+	require.NoError(db.QueryRowContext(ctx,
+		"SELECT count(*) FROM pg_catalog.pg_database WHERE starts_with(datname, $1)",
+		shadowPrefix).Scan(&count))
+	require.Zero(count)
 }
@@ -0,0 +1,145 @@
+package squire
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/squire/internal/sqlbuild"
+)
+
+// testFixtures loads all the fixture files (files ending in "_fixture.sql")
+// into the test database. Fixtures are loaded in the same order as the
+// schema and are committed, so they're visible to every test.
+func (s *Squire) testFixtures(ctx context.Context, db *sql.DB) error {
+	L := s.logger.Named("fixture")
+
+	rootDir, rootFile, err := s.sqlRoot()
+	if err != nil {
+		return err
+	}
+
+	files, err := sqlbuild.Files(os.DirFS(rootDir), rootFile, sqlbuild.FixtureSuffix, L)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		L.Debug("loading fixture", "file", f)
+		src, err := ioutil.ReadFile(filepath.Join(rootDir, f))
+		if err != nil {
+			return err
+		}
+
+		if err := s.Deploy(ctx, &DeployOptions{
+			SQL:    bytes.NewReader(src),
+			Target: db,
+		}); err != nil {
+			return fmt.Errorf("error loading fixture %s: %w", f, err)
+		}
+	}
+
+	return nil
+}
+
+// testSetups stores the statements from the setup files (files ending in
+// "_setup.sql") for every test case so that the test runner can execute
+// them prior to each test. The schema is the built SQL schema (with tests)
+// and is used to determine which file each test case is defined in.
+func (s *Squire) testSetups(ctx context.Context, db *sql.DB, schema []byte) error {
+	L := s.logger.Named("setup")
+
+	rootDir, rootFile, err := s.sqlRoot()
+	if err != nil {
+		return err
+	}
+
+	files, err := sqlbuild.Files(os.DirFS(rootDir), rootFile, sqlbuild.SetupSuffix, L)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	// Read all our setup files grouped by directory
+	setups := map[string][]string{}
+	for _, f := range files {
+		src, err := ioutil.ReadFile(filepath.Join(rootDir, f))
+		if err != nil {
+			return err
+		}
+
+		dir := setupDir(f)
+		setups[dir] = append(setups[dir], splitStatements(string(src))...)
+	}
+
+	funcs, err := functionFiles(schema)
+	if err != nil {
+		return err
+	}
+
+	tests := setupsByTest(funcs, setups)
+	names := make([]string, 0, len(tests))
+	for name := range tests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		L.Trace("storing setup", "test", name)
+		if _, err := db.ExecContext(ctx,
+			"INSERT INTO squire_test.setups (test_name, statements) VALUES ($1, $2)",
+			name, tests[name],
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setupsByTest returns the setup statements for every test case. funcs
+// is the mapping of function name to file (see functionFiles) and setups
+// is the mapping of directory to statements (see setupDir). Test cases
+// without any setup statements are omitted.
+func setupsByTest(funcs map[string]string, setups map[string][]string) map[string][]string {
+	result := map[string][]string{}
+	for name, file := range funcs {
+		// Strip any schema, test cases are matched by name alone.
+		if idx := strings.LastIndexByte(name, '.'); idx >= 0 {
+			name = name[idx+1:]
+		}
+		if !strings.HasPrefix(name, "test_case_") {
+			continue
+		}
+
+		dir := setupDir(file)
+		if dir == "" || len(setups[dir]) == 0 {
+			continue
+		}
+
+		result[name] = setups[dir]
+	}
+
+	return result
+}
+
+// setupDir returns the "NN-" directory that a file within the SQL directory
+// belongs to. The path is relative to the parent of the SQL directory, such
+// as "sql/01-accounts/foo.sql". If the file isn't within a directory (such as
+// "sql/01-foo.sql"), then an empty string is returned.
+func setupDir(p string) string {
+	parts := strings.SplitN(p, "/", 3)
+	if len(parts) < 3 {
+		return ""
+	}
+
+	return parts[0] + "/" + parts[1]
+}
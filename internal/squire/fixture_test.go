@@ -0,0 +1,45 @@
+package squire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetupDir(t *testing.T) {
+	cases := []struct {
+		Path     string
+		Expected string
+	}{
+		{"sql/01-accounts/foo_test.sql", "sql/01-accounts"},
+		{"sql/01-accounts/nested/foo_test.sql", "sql/01-accounts"},
+		{"sql/01-foo_test.sql", ""},
+		{"", ""},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Path, func(t *testing.T) {
+			require.Equal(t, tt.Expected, setupDir(tt.Path))
+		})
+	}
+}
+
+func TestSetupsByTest(t *testing.T) {
+	require := require.New(t)
+
+	funcs := map[string]string{
+		"account_with_org":             "sql/01-accounts/func.sql",
+		"test_case_account":            "sql/01-accounts/func_test.sql",
+		"app.test_case_account_schema": "sql/01-accounts/app_test.sql",
+		"test_case_org":                "sql/02-orgs/org_test.sql",
+		"test_case_top":                "sql/03-top_test.sql",
+	}
+	setups := map[string][]string{
+		"sql/01-accounts": {"INSERT INTO accounts DEFAULT VALUES"},
+	}
+
+	require.Equal(map[string][]string{
+		"test_case_account":        {"INSERT INTO accounts DEFAULT VALUES"},
+		"test_case_account_schema": {"INSERT INTO accounts DEFAULT VALUES"},
+	}, setupsByTest(funcs, setups))
+}
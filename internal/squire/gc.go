@@ -0,0 +1,174 @@
+package squire
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+
+	"github.com/mitchellh/squire/internal/dbcontainer"
+	"github.com/mitchellh/squire/internal/pkg/stdcapture"
+)
+
+type GCOptions struct {
+	// Container is the primary dev container. Clones of this container
+	// are collected. If this is nil, the default Container is used.
+	Container dbcontainer.Container
+
+	// MinAge is the minimum age of a clone to be collected. This avoids
+	// destroying clones that are in use by a Squire process that is still
+	// running. If this is zero, clones of any age are collected.
+	MinAge time.Duration
+
+	// All, if true, collects all clones: the kept test container
+	// ("squire test -keep") and the pool containers are collected as well.
+	// MinAge still applies.
+	All bool
+
+	// DryRun, if true, only reports the clones that would be collected.
+	DryRun bool
+
+	// Callback is called for every clone that is collected, prior to
+	// destroying it.
+	Callback func(*dbcontainer.CloneInfo)
+}
+
+// GC destroys clones of the dev container that were left behind. Clones
+// are normally destroyed when the operation that created them completes,
+// but they can be left behind if Squire crashes or is killed. Clones are
+// found by the backend, i.e. by their Docker label (see
+// dbcontainer.Container.Clones). Only clones created for a single
+// operation are collected unless All is set. Destroying a clone destroys
+// its checkpoints too (see dbcontainer.CheckpointPrefix).
+//
+// Shadow databases left behind in the dev container are collected as
+// well if it is running. These are reported to Callback as clones whose
+// project is the name of the database.
+func (s *Squire) GC(ctx context.Context, opts *GCOptions) error {
+	L := s.logger.Named("gc")
+
+	var err error
+	if opts.Container == nil {
+		opts.Container, err = s.Container()
+		if err != nil {
+			return err
+		}
+	}
+	if opts.Callback == nil {
+		opts.Callback = func(*dbcontainer.CloneInfo) {}
+	}
+
+	clones, err := opts.Container.Clones(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, info := range gcFilter(clones, opts, time.Now()) {
+		opts.Callback(info)
+		if opts.DryRun {
+			continue
+		}
+
+		L.Info("destroying clone", "project", info.Project)
+
+		// We need to capture stdout/stderr because the compose API doesn't
+		// allow configurable output streams.
+		err := stdcapture.SuccessOnly(ioutil.Discard, ioutil.Discard, func() error {
+			return opts.Container.DownClone(ctx, info.Project)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.gcShadow(ctx, opts)
+}
+
+// gcShadow collects the shadow databases left behind in the dev
+// container, see GC.
+func (s *Squire) gcShadow(ctx context.Context, opts *GCOptions) error {
+	L := s.logger.Named("gc")
+
+	st, err := opts.Container.Status(ctx)
+	if err != nil {
+		return err
+	}
+	if st.State != dbcontainer.Running {
+		return nil
+	}
+
+	admin, _, err := adminDB(ctx, opts.Container.ConnURI())
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+
+	rows, err := admin.QueryContext(ctx,
+		"SELECT datname FROM pg_catalog.pg_database WHERE starts_with(datname, $1) ORDER BY datname",
+		shadowPrefix)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var shadows []*dbcontainer.CloneInfo
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+
+		created, ok := shadowCreated(name)
+		if !ok {
+			continue
+		}
+
+		shadows = append(shadows, &dbcontainer.CloneInfo{
+			Project:   name,
+			Name:      strings.TrimPrefix(name, shadowPrefix),
+			Created:   created,
+			Ephemeral: true,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, info := range gcFilter(shadows, opts, time.Now()) {
+		opts.Callback(info)
+		if opts.DryRun {
+			continue
+		}
+
+		L.Info("dropping shadow database", "database", info.Project)
+		if _, err := admin.ExecContext(ctx, "DROP DATABASE IF EXISTS "+
+			pgx.Identifier{info.Project}.Sanitize()+" WITH (FORCE)"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// gcFilter returns the clones that should be collected.
+func gcFilter(
+	clones []*dbcontainer.CloneInfo,
+	opts *GCOptions,
+	now time.Time,
+) []*dbcontainer.CloneInfo {
+	var result []*dbcontainer.CloneInfo
+	for _, info := range clones {
+		if !info.Ephemeral && !opts.All {
+			continue
+		}
+		if now.Sub(info.Created) < opts.MinAge {
+			continue
+		}
+
+		result = append(result, info)
+	}
+
+	return result
+}
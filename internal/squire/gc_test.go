@@ -0,0 +1,39 @@
+package squire
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mitchellh/squire/internal/dbcontainer"
+)
+
+func TestGCFilter(t *testing.T) {
+	require := require.New(t)
+
+	now := time.Now()
+	old := now.Add(-1 * time.Hour)
+	clones := []*dbcontainer.CloneInfo{
+		{Project: "app-diff-1", Ephemeral: true, Created: old},
+		{Project: "app-diff-2", Ephemeral: true, Created: now},
+		{Project: "app-pool-0", Pool: true, Created: old},
+		{Project: "app-test", Created: old},
+	}
+
+	names := func(opts *GCOptions) []string {
+		var result []string
+		for _, info := range gcFilter(clones, opts, now) {
+			result = append(result, info.Project)
+		}
+
+		return result
+	}
+
+	require.Equal([]string{"app-diff-1"},
+		names(&GCOptions{MinAge: 10 * time.Minute}))
+	require.Equal([]string{"app-diff-1", "app-pool-0", "app-test"},
+		names(&GCOptions{MinAge: 10 * time.Minute, All: true}))
+	require.Equal([]string{"app-diff-1", "app-diff-2"},
+		names(&GCOptions{}))
+}
@@ -0,0 +1,79 @@
+package squire
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/hexops/gotextdiff"
+	"github.com/hexops/gotextdiff/myers"
+	"github.com/hexops/gotextdiff/span"
+)
+
+// GoldenResult is the result of a single test file whose output is
+// compared to a golden file, such as query plan and snapshot tests.
+type GoldenResult struct {
+	// File is the path to the SQL test file and Golden is the path to
+	// the golden file with the expected output. Both are relative to the
+	// parent of the SQL directory.
+	File   string
+	Golden string
+
+	// Passed is true if the output matched the golden file. Updated is
+	// true if the golden file was written during this run.
+	Passed  bool
+	Updated bool
+
+	// Diff is a unified diff between the golden file and the actual
+	// output. This is only set if Passed is false.
+	Diff string
+}
+
+// checkGolden compares actual to the contents of the golden file in r
+// and populates the result. If update is true, the golden file is written
+// instead if it differs.
+func checkGolden(rootDir string, r *GoldenResult, actual []byte, update bool) error {
+	goldenPath := filepath.Join(rootDir, r.Golden)
+	expected, err := ioutil.ReadFile(goldenPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	exists := err == nil
+
+	if exists && bytes.Equal(expected, actual) {
+		r.Passed = true
+		return nil
+	}
+
+	if update {
+		if err := ioutil.WriteFile(goldenPath, actual, 0644); err != nil {
+			return err
+		}
+
+		r.Passed = true
+		r.Updated = true
+		return nil
+	}
+
+	if !exists {
+		r.Diff = fmt.Sprintf(
+			"golden file %s does not exist, run with updates enabled to create it",
+			r.Golden)
+		return nil
+	}
+
+	expectedString := string(expected)
+	actualString := string(actual)
+	edits := myers.ComputeEdits(span.URIFromPath(r.Golden), expectedString, actualString)
+	r.Diff = fmt.Sprint(gotextdiff.ToUnified(r.Golden, "actual", expectedString, edits))
+	return nil
+}
+
+// goldenPath returns the path to the golden file for a test file with
+// the given extension. For "foo_plan.sql" and "golden" this is
+// "foo_plan.golden".
+func goldenPath(p, ext string) string {
+	return p[:len(p)-len(filepath.Ext(p))] + "." + ext
+}
@@ -0,0 +1,46 @@
+package squire
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckGolden(t *testing.T) {
+	require := require.New(t)
+
+	td, err := ioutil.TempDir("", "squire")
+	require.NoError(err)
+	defer os.RemoveAll(td)
+
+	// Missing golden file should fail
+	r := &GoldenResult{File: "a_plan.sql", Golden: goldenPath("a_plan.sql", "golden")}
+	require.Equal("a_plan.golden", r.Golden)
+	require.NoError(checkGolden(td, r, []byte("hello\n"), false))
+	require.False(r.Passed)
+	require.NotEmpty(r.Diff)
+
+	// Update should write it
+	r = &GoldenResult{File: "a_plan.sql", Golden: "a_plan.golden"}
+	require.NoError(checkGolden(td, r, []byte("hello\n"), true))
+	require.True(r.Passed)
+	require.True(r.Updated)
+	bs, err := ioutil.ReadFile(filepath.Join(td, "a_plan.golden"))
+	require.NoError(err)
+	require.Equal("hello\n", string(bs))
+
+	// Same contents should pass without updating
+	r = &GoldenResult{File: "a_plan.sql", Golden: "a_plan.golden"}
+	require.NoError(checkGolden(td, r, []byte("hello\n"), true))
+	require.True(r.Passed)
+	require.False(r.Updated)
+
+	// Different contents should fail with a diff
+	r = &GoldenResult{File: "a_plan.sql", Golden: "a_plan.golden"}
+	require.NoError(checkGolden(td, r, []byte("world\n"), false))
+	require.False(r.Passed)
+	require.Contains(r.Diff, "+world")
+}
@@ -0,0 +1,120 @@
+package squire
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/mitchellh/squire/internal/sqlbuild"
+)
+
+// testPlans runs the query plan tests (files ending in "_plan.sql") and
+// compares the EXPLAIN output to the golden files alongside them. If
+// update is true, the golden files are written rather than compared.
+func (s *Squire) testPlans(
+	ctx context.Context,
+	db *sql.DB,
+	update bool,
+) ([]*GoldenResult, error) {
+	L := s.logger.Named("plan")
+
+	rootDir, rootFile, err := s.sqlRoot()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := sqlbuild.Files(os.DirFS(rootDir), rootFile, sqlbuild.PlanSuffix, L)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*GoldenResult
+	for _, f := range files {
+		L := L.With("file", f)
+		L.Debug("running plan test")
+
+		r := &GoldenResult{
+			File:   f,
+			Golden: goldenPath(f, "golden"),
+		}
+		result = append(result, r)
+
+		src, err := ioutil.ReadFile(filepath.Join(rootDir, f))
+		if err != nil {
+			return nil, err
+		}
+
+		actual, err := explainAll(ctx, db, string(src))
+		if err != nil {
+			return nil, fmt.Errorf("error explaining queries in %s: %w", f, err)
+		}
+
+		if err := checkGolden(rootDir, r, actual, update); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// explainAll runs EXPLAIN for every statement in src and returns the
+// combined output. Statements that change settings (SET and RESET) are
+// executed rather than explained so that plan files can tune the planner,
+// for example with "SET enable_seqscan = off".
+func explainAll(ctx context.Context, db *sql.DB, src string) ([]byte, error) {
+	// We use a single connection so that settings apply to subsequent
+	// statements. We discard any state from prior use of the connection.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "DISCARD ALL"); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	for i, stmt := range splitStatements(src) {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(&buf, "%s;\n", stmt)
+
+		if reSettingStatement.MatchString(stmt) {
+			if _, err := conn.ExecContext(ctx, stmt); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		rows, err := conn.QueryContext(ctx, "EXPLAIN (COSTS OFF) "+stmt)
+		if err != nil {
+			return nil, err
+		}
+
+		buf.WriteString("\n")
+		for rows.Next() {
+			var line string
+			if err := rows.Scan(&line); err != nil {
+				rows.Close()
+				return nil, err
+			}
+
+			fmt.Fprintf(&buf, "%s\n", line)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+var reSettingStatement = regexp.MustCompile(`(?is)^(?:--[^\n]*\n\s*)*(?:set|reset)\s`)
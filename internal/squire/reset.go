@@ -1,15 +1,18 @@
 package squire
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"io"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/cenkalti/backoff/v4"
+	"github.com/cockroachdb/errors"
+	"github.com/jackc/pgx/v4"
 
-	"github.com/mitchellh/squire/internal/dbcompose"
 	"github.com/mitchellh/squire/internal/dbcontainer"
 )
 
@@ -18,16 +21,31 @@ type ResetOptions struct {
 	// non-dev mode (at the moment) because we need to recreate the whole
 	// database which requires superuser. If this isn't set, the default
 	// Container will be used.
-	Container *dbcontainer.Container
+	Container dbcontainer.Container
 
 	// Schema to apply upon reset. If this isn't set, a default schema
 	// will be loaded by calling Schema.
 	Schema io.Reader
+
+	// Incremental, if true, checkpoints the database after applying each
+	// "NN-" directory (or file) in the SQL directory. A checkpoint is a
+	// template database named after the hash of all the SQL up to that
+	// point. Subsequent incremental resets copy the last checkpoint that is
+	// still valid and only apply the SQL after it. A step with a top-level
+	// SET or RESET statement is applied together with every step after it
+	// without checkpoints between them, since each step is otherwise
+	// deployed on its own connection and would lose the setting. This is
+	// ignored if Schema is set.
+	Incremental bool
 }
 
 // Reset recreates the entire database quickly by dropping the
 // database, recreating, and reapplying the SQL. It doesn't recreate
 // the container by default.
+//
+// If the reset fails, the last lines of the server log are attached to
+// the error as detail since the cause is often only logged by the server,
+// i.e. a missing extension or running out of shared memory.
 func (s *Squire) Reset(ctx context.Context, opts *ResetOptions) error {
 	L := s.logger.Named("reset")
 	L.Info("starting reset")
@@ -40,9 +58,40 @@ func (s *Squire) Reset(ctx context.Context, opts *ResetOptions) error {
 		}
 	}
 
+	// The build is hashed first so that a change to the SQL files during
+	// the reset makes the schema out of date rather than current.
+	var hash string
+	if opts.Schema == nil {
+		hash, err = s.buildHash()
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := s.reset(ctx, opts); err != nil {
+		return withServerLog(ctx, opts.Container, err)
+	}
+
+	// Record the build so that Status can tell if the schema is current.
+	if hash != "" {
+		L.Debug("recording the hash of the SQL build", "hash", hash)
+		return recordBuildHash(ctx, opts.Container, hash)
+	}
+
+	return nil
+}
+
+// reset implements Reset.
+func (s *Squire) reset(ctx context.Context, opts *ResetOptions) error {
+	L := s.logger.Named("reset")
+
+	if opts.Incremental && opts.Schema == nil {
+		return s.resetIncremental(ctx, opts.Container)
+	}
+
 	// Recreate the database first
 	L.Debug("recreating the logical database")
-	if err := recreateDB(ctx, opts.Container.Config()); err != nil {
+	if err := recreateDB(ctx, opts.Container.ConnURI()); err != nil {
 		L.Error("error recreating the db", "err", err)
 		return err
 	}
@@ -67,14 +116,72 @@ func (s *Squire) Reset(ctx context.Context, opts *ResetOptions) error {
 	return nil
 }
 
+// withServerLog attaches the last lines of the server log of the
+// container to err as detail. If the log can't be read, err is returned
+// unchanged.
+func withServerLog(ctx context.Context, ctr dbcontainer.Container, err error) error {
+	if ctx.Err() != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if logErr := ctr.Logs(ctx, &buf, dbcontainer.LogOptions{
+		Tail: serverLogLines,
+	}); logErr != nil || buf.Len() == 0 {
+		return err
+	}
+
+	return errors.WithDetailf(err,
+		"The last lines of the PostgreSQL server log are below:\n\n%s",
+		strings.TrimRight(buf.String(), "\n"),
+	)
+}
+
 // recreateDB recreates the currently selected database by issusing a
-// DROP DATABASE followed by a CREATE DATABASE.
-func recreateDB(ctx context.Context, cfg *dbcompose.Config) error {
-	// Get our conn URL
-	u, err := url.Parse(cfg.ConnURI())
+// DROP DATABASE followed by a CREATE DATABASE. The database doesn't have
+// to exist.
+func recreateDB(ctx context.Context, uri string) error {
+	db, dbname, err := adminDB(ctx, uri)
 	if err != nil {
 		return err
 	}
+	defer db.Close()
+
+	return recreateDBFrom(ctx, db, dbname, "")
+}
+
+// recreateDBFrom drops and creates the database dbname using the given
+// admin connection (see adminDB). If template is non-empty, the database
+// is created as a copy of the template database.
+func recreateDBFrom(ctx context.Context, db *sql.DB, dbname, template string) error {
+	// Drop our database
+	// NOTE: This query requires PG13+
+	if _, err := db.ExecContext(ctx,
+		"DROP DATABASE IF EXISTS "+pgx.Identifier{dbname}.Sanitize()+" WITH (FORCE)"); err != nil {
+		return err
+	}
+
+	// Create
+	q := "CREATE DATABASE " + pgx.Identifier{dbname}.Sanitize()
+	if template != "" {
+		q += " TEMPLATE " + pgx.Identifier{template}.Sanitize()
+	}
+	if _, err := db.ExecContext(ctx, q); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// adminDB connects to the "postgres" database on the server for the
+// given connection URI so that the database in the URI can be dropped and
+// created. The name of that database is returned as well.
+func adminDB(ctx context.Context, uri string) (*sql.DB, string, error) {
+	// Get our conn URL
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, "", err
+	}
 
 	// Get our prior db
 	dbname := u.Path
@@ -88,9 +195,8 @@ func recreateDB(ctx context.Context, cfg *dbcompose.Config) error {
 	// Connect,
 	db, err := sql.Open("pgx", u.String())
 	if err != nil {
-		return err
+		return nil, "", err
 	}
-	defer db.Close()
 
 	// Wait for the connection to become ready
 	err = backoff.Retry(func() error {
@@ -101,19 +207,14 @@ func recreateDB(ctx context.Context, cfg *dbcompose.Config) error {
 	))
 	if err != nil {
 		db.Close()
-		return err
-	}
-
-	// Drop our database
-	// NOTE: This query requires PG13+
-	if _, err := db.ExecContext(ctx, `DROP DATABASE "`+dbname+`" WITH (FORCE)`); err != nil {
-		return err
+		return nil, "", err
 	}
 
-	// Create
-	if _, err := db.ExecContext(ctx, `CREATE DATABASE "`+dbname+`"`); err != nil {
-		return err
-	}
-
-	return nil
+	return db, dbname, nil
 }
+
+const (
+	// serverLogLines is the number of lines of the server log attached to
+	// errors, see withServerLog.
+	serverLogLines = 20
+)
@@ -0,0 +1,45 @@
+package squire
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mitchellh/squire/internal/dbcontainer"
+)
+
+// logContainer is a container that only has a server log.
+type logContainer struct {
+	dbcontainer.Container
+
+	log string
+}
+
+func (c *logContainer) Logs(ctx context.Context, w io.Writer, opts dbcontainer.LogOptions) error {
+	if c.log == "" {
+		return errors.New("no log")
+	}
+
+	_, err := fmt.Fprint(w, c.log)
+	return err
+}
+
+func TestWithServerLog(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	cause := errors.New("reset failed")
+
+	err := withServerLog(ctx, &logContainer{
+		log: "ERROR:  could not open extension control file\n",
+	}, cause)
+	require.True(errors.Is(err, cause))
+	require.Contains(errors.FlattenDetails(err), "could not open extension control file")
+
+	// The error is unchanged if there's no log
+	err = withServerLog(ctx, &logContainer{}, cause)
+	require.Equal(cause, err)
+}
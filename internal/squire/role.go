@@ -0,0 +1,192 @@
+package squire
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jackc/pgconn"
+
+	"github.com/mitchellh/squire/internal/dbcontainer"
+	"github.com/mitchellh/squire/internal/sqlbuild"
+)
+
+// testRole is the role and settings that a test case runs as, from the
+// "-- squire:test-role" annotation.
+type testRole struct {
+	Role     string
+	Settings map[string]string
+}
+
+// testRoles creates the roles used by tests in the test container. These
+// are the roles from the configuration and the roles files (files ending
+// in "_roles.sql"). Roles are global to the server and are not removed by
+// Reset, so this must be called prior to applying the schema since the
+// schema may reference them (i.e. in policies).
+//
+// Roles may already exist if the test container was kept, so roles that
+// already exist are ignored.
+func (s *Squire) testRoles(ctx context.Context, ctr dbcontainer.Container) error {
+	L := s.logger.Named("role")
+
+	rootDir, rootFile, err := s.sqlRoot()
+	if err != nil {
+		return err
+	}
+
+	files, err := sqlbuild.Files(os.DirFS(rootDir), rootFile, sqlbuild.RolesSuffix, L)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 && len(s.config.Test.Roles) == 0 {
+		return nil
+	}
+
+	db, err := ctr.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var stmts []string
+	for _, role := range s.config.Test.Roles {
+		stmts = append(stmts, fmt.Sprintf(`CREATE ROLE "%s" NOLOGIN`,
+			strings.ReplaceAll(role, `"`, `""`)))
+	}
+	for _, f := range files {
+		src, err := ioutil.ReadFile(filepath.Join(rootDir, f))
+		if err != nil {
+			return err
+		}
+
+		stmts = append(stmts, splitStatements(string(src))...)
+	}
+
+	for _, stmt := range stmts {
+		L.Debug("creating role", "sql", stmt)
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			pgerr := &pgconn.PgError{}
+			if errors.As(err, &pgerr) && pgerr.Code == pgDuplicateObject {
+				L.Debug("role already exists", "err", err)
+				continue
+			}
+
+			return fmt.Errorf("error creating test roles: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// testRoleAnnotations stores the roles for every test case annotated with
+// "-- squire:test-role" so that the test runner can switch to them. The
+// schema is the built SQL schema (with tests).
+func (s *Squire) testRoleAnnotations(ctx context.Context, db *sql.DB, schema []byte) error {
+	roles, err := roleAnnotations(schema)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(roles))
+	for name := range roles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		role := roles[name]
+		settings, err := json.Marshal(role.Settings)
+		if err != nil {
+			return err
+		}
+
+		s.logger.Named("role").Trace("storing test role", "test", name, "role", role.Role)
+		if _, err := db.ExecContext(ctx,
+			"INSERT INTO squire_test.test_roles (test_name, role_name, settings) VALUES ($1, $2, $3::jsonb)",
+			name, role.Role, string(settings),
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// roleAnnotations parses the built schema and returns the role for every
+// test case that is annotated with "-- squire:test-role". The annotation
+// must be in the comment lines directly preceding the CREATE FUNCTION
+// statement and has the format:
+//
+//	-- squire:test-role <role> [<setting>=<value> ...]
+func roleAnnotations(schema []byte) (map[string]*testRole, error) {
+	result := map[string]*testRole{}
+
+	var pending *testRole
+	scanner := bufio.NewScanner(bytes.NewReader(schema))
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if m := reTestRole.FindStringSubmatch(line); m != nil {
+			fields := strings.Fields(m[1])
+			if len(fields) == 0 {
+				return nil, errors.Newf("test role annotation requires a role: %q", line)
+			}
+
+			pending = &testRole{Role: fields[0], Settings: map[string]string{}}
+			for _, field := range fields[1:] {
+				idx := strings.IndexByte(field, '=')
+				if idx <= 0 {
+					return nil, errors.Newf(
+						"test role setting must be in the format key=value: %q", field)
+				}
+
+				pending.Settings[field[:idx]] = field[idx+1:]
+			}
+
+			continue
+		}
+
+		// Other comments don't affect a pending annotation.
+		if strings.HasPrefix(line, "--") {
+			continue
+		}
+
+		if m := reCreateFunction.FindStringSubmatch(line); m != nil && pending != nil {
+			name := m[1]
+			if idx := strings.LastIndexByte(name, '.'); idx >= 0 {
+				name = name[idx+1:]
+			}
+			if strings.HasPrefix(name, `"`) {
+				name = strings.Trim(name, `"`)
+			} else {
+				name = strings.ToLower(name)
+			}
+
+			if strings.HasPrefix(name, "test_case_") {
+				result[name] = pending
+			}
+		}
+
+		pending = nil
+	}
+
+	return result, scanner.Err()
+}
+
+var reTestRole = regexp.MustCompile(`^--\s*squire:test-role\b(.*)$`)
+
+const (
+	// pgDuplicateObject is the SQLSTATE for duplicate_object errors, which
+	// is returned when creating a role that already exists.
+	pgDuplicateObject = "42710"
+)
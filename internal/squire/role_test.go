@@ -0,0 +1,52 @@
+package squire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoleAnnotations(t *testing.T) {
+	require := require.New(t)
+
+	schema := []byte(`
+-- squire:test-role anon
+CREATE FUNCTION test_case_anon() RETURNS VOID AS $$ BEGIN END; $$ LANGUAGE plpgsql;
+
+-- Comments between the annotation and function are fine.
+-- squire:test-role app_user request.jwt.claim.sub=42 request.jwt.claim.role=admin
+-- Another comment
+CREATE OR REPLACE FUNCTION public.test_case_user() RETURNS VOID AS $$ BEGIN END; $$ LANGUAGE plpgsql;
+
+-- squire:test-role anon
+
+CREATE FUNCTION test_case_separated() RETURNS VOID AS $$ BEGIN END; $$ LANGUAGE plpgsql;
+
+CREATE FUNCTION test_case_none() RETURNS VOID AS $$ BEGIN END; $$ LANGUAGE plpgsql;
+
+-- squire:test-role anon
+CREATE FUNCTION not_a_test() RETURNS VOID AS $$ BEGIN END; $$ LANGUAGE plpgsql;
+`)
+
+	roles, err := roleAnnotations(schema)
+	require.NoError(err)
+	require.Equal(map[string]*testRole{
+		"test_case_anon": {
+			Role:     "anon",
+			Settings: map[string]string{},
+		},
+		"test_case_user": {
+			Role: "app_user",
+			Settings: map[string]string{
+				"request.jwt.claim.sub":  "42",
+				"request.jwt.claim.role": "admin",
+			},
+		},
+	}, roles)
+
+	// Invalid annotations
+	_, err = roleAnnotations([]byte("-- squire:test-role\n"))
+	require.Error(err)
+	_, err = roleAnnotations([]byte("-- squire:test-role anon sub\n"))
+	require.Error(err)
+}
@@ -0,0 +1,236 @@
+-- Squire test runtime: assertions.
+--
+-- This is installed by Squire into the test database alongside pgUnit.
+-- These functions complement the pgUnit assertions. Just like pgUnit, a
+-- failed assertion raises triggered_action_exception so the test is reported
+-- as failed rather than erroneous.
+
+CREATE SCHEMA IF NOT EXISTS squire_test;
+
+--
+-- Returns a line-by-line diff of the expected and actual values. Equal
+-- lines are prefixed with two spaces, expected lines with "- " and actual
+-- lines with "+ ". NULL is rendered as "NULL".
+--
+CREATE OR REPLACE FUNCTION squire_test.diff(expected TEXT, actual TEXT)
+RETURNS TEXT AS $$
+DECLARE
+  l_expected TEXT[] := string_to_array(COALESCE(expected, 'NULL'), E'\n');
+  l_actual TEXT[] := string_to_array(COALESCE(actual, 'NULL'), E'\n');
+  l_result TEXT[] := '{}';
+  l_len INTEGER;
+BEGIN
+  l_len := greatest(
+    COALESCE(array_length(l_expected, 1), 0),
+    COALESCE(array_length(l_actual, 1), 0));
+  FOR idx IN 1 .. l_len LOOP
+    IF l_expected[idx] IS NOT DISTINCT FROM l_actual[idx] THEN
+      l_result := l_result || ('  ' || l_expected[idx]);
+      CONTINUE;
+    END IF;
+
+    IF l_expected[idx] IS NOT NULL THEN
+      l_result := l_result || ('- ' || l_expected[idx]);
+    END IF;
+    IF l_actual[idx] IS NOT NULL THEN
+      l_result := l_result || ('+ ' || l_actual[idx]);
+    END IF;
+  END LOOP;
+
+  RETURN array_to_string(l_result, E'\n');
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+--
+-- Asserts that two values are equal. NULL is equal to NULL. The failure
+-- message contains a diff of the two values.
+--
+CREATE OR REPLACE FUNCTION squire_test.assert_equals(
+  message TEXT, expected ANYELEMENT, actual ANYELEMENT)
+RETURNS VOID AS $$
+BEGIN
+  IF expected IS DISTINCT FROM actual THEN
+    RAISE EXCEPTION E'assert_equals failure: %\n%',
+      message, squire_test.diff(expected::TEXT, actual::TEXT)
+      USING ERRCODE = 'triggered_action_exception';
+  END IF;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION squire_test.assert_equals(
+  expected ANYELEMENT, actual ANYELEMENT)
+RETURNS VOID AS $$
+BEGIN
+  PERFORM squire_test.assert_equals('values are not equal', expected, actual);
+END;
+$$ LANGUAGE plpgsql;
+
+--
+-- Asserts that two values are not equal. NULL is equal to NULL.
+--
+CREATE OR REPLACE FUNCTION squire_test.assert_not_equals(
+  message TEXT, unexpected ANYELEMENT, actual ANYELEMENT)
+RETURNS VOID AS $$
+BEGIN
+  IF unexpected IS NOT DISTINCT FROM actual THEN
+    RAISE EXCEPTION 'assert_not_equals failure: %: both values are %',
+      message, COALESCE(actual::TEXT, 'NULL')
+      USING ERRCODE = 'triggered_action_exception';
+  END IF;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION squire_test.assert_not_equals(
+  unexpected ANYELEMENT, actual ANYELEMENT)
+RETURNS VOID AS $$
+BEGIN
+  PERFORM squire_test.assert_not_equals('values are equal', unexpected, actual);
+END;
+$$ LANGUAGE plpgsql;
+
+--
+-- Asserts that executing the given SQL raises an error. If the SQLSTATE is
+-- given, the error must have that code (i.e. '23505' for a unique
+-- violation). If the SQL raises an error, its changes are rolled back. If
+-- it succeeds, the assertion fails but this function doesn't roll back the
+-- changes made by the SQL.
+--
+CREATE OR REPLACE FUNCTION squire_test.assert_raises(
+  query TEXT, expected_sqlstate TEXT DEFAULT NULL)
+RETURNS VOID AS $$
+DECLARE
+  l_sqlstate TEXT;
+  l_message TEXT;
+BEGIN
+  BEGIN
+    EXECUTE query;
+  EXCEPTION WHEN OTHERS THEN
+    GET STACKED DIAGNOSTICS
+      l_sqlstate = RETURNED_SQLSTATE,
+      l_message = MESSAGE_TEXT;
+  END;
+
+  IF l_sqlstate IS NULL THEN
+    RAISE EXCEPTION 'assert_raises failure: expected an error% but none was raised: %',
+      COALESCE(' with SQLSTATE ' || expected_sqlstate, ''), query
+      USING ERRCODE = 'triggered_action_exception';
+  END IF;
+
+  IF expected_sqlstate IS NOT NULL AND l_sqlstate <> upper(expected_sqlstate) THEN
+    RAISE EXCEPTION 'assert_raises failure: expected SQLSTATE % but got %: %',
+      expected_sqlstate, l_sqlstate, l_message
+      USING ERRCODE = 'triggered_action_exception';
+  END IF;
+END;
+$$ LANGUAGE plpgsql;
+
+--
+-- Asserts that two queries return the same rows. The order of the rows
+-- is not compared, but duplicate rows are. The failure message contains the
+-- rows that are missing from and extra in the actual query.
+--
+CREATE OR REPLACE FUNCTION squire_test.assert_rows_equal(
+  expected_query TEXT, actual_query TEXT)
+RETURNS VOID AS $$
+DECLARE
+  l_missing TEXT;
+  l_extra TEXT;
+  l_diff_query TEXT := $q$
+    SELECT string_agg(r::TEXT, E'\n' ORDER BY r::TEXT) FROM (
+      SELECT * FROM (%s) a EXCEPT ALL SELECT * FROM (%s) b
+    ) r
+  $q$;
+BEGIN
+  EXECUTE format(l_diff_query, expected_query, actual_query) INTO l_missing;
+  EXECUTE format(l_diff_query, actual_query, expected_query) INTO l_extra;
+  IF l_missing IS NULL AND l_extra IS NULL THEN
+    RETURN;
+  END IF;
+
+  RAISE EXCEPTION E'assert_rows_equal failure: rows are not equal\n%',
+    array_to_string(ARRAY[
+      (SELECT string_agg('- ' || line, E'\n')
+       FROM unnest(string_to_array(l_missing, E'\n')) line),
+      (SELECT string_agg('+ ' || line, E'\n')
+       FROM unnest(string_to_array(l_extra, E'\n')) line)
+    ], E'\n')
+    USING ERRCODE = 'triggered_action_exception';
+END;
+$$ LANGUAGE plpgsql;
+
+--
+-- Asserts that the table has an index with the given name.
+--
+CREATE OR REPLACE FUNCTION squire_test.assert_has_index(
+  table_name TEXT, index_name TEXT)
+RETURNS VOID AS $$
+BEGIN
+  IF NOT EXISTS (
+    SELECT 1 FROM pg_catalog.pg_index i
+    JOIN pg_catalog.pg_class c ON c.oid = i.indexrelid
+    WHERE i.indrelid = to_regclass(table_name) AND c.relname = index_name
+  ) THEN
+    RAISE EXCEPTION 'assert_has_index failure: table % has no index %',
+      table_name, index_name
+      USING ERRCODE = 'triggered_action_exception';
+  END IF;
+END;
+$$ LANGUAGE plpgsql;
+
+--
+-- Asserts that the table has an index on exactly the given columns in
+-- the given order. The index name doesn't matter.
+--
+CREATE OR REPLACE FUNCTION squire_test.assert_has_index(
+  table_name TEXT, columns TEXT[])
+RETURNS VOID AS $$
+BEGIN
+  IF NOT EXISTS (
+    SELECT 1 FROM pg_catalog.pg_index i
+    WHERE i.indrelid = to_regclass(table_name)
+      AND ARRAY(
+        SELECT a.attname::TEXT
+        FROM unnest(i.indkey) WITH ORDINALITY k(attnum, n)
+        JOIN pg_catalog.pg_attribute a
+          ON a.attrelid = i.indrelid AND a.attnum = k.attnum
+        ORDER BY k.n
+      ) = columns
+  ) THEN
+    RAISE EXCEPTION 'assert_has_index failure: table % has no index on (%)',
+      table_name, array_to_string(columns, ', ')
+      USING ERRCODE = 'triggered_action_exception';
+  END IF;
+END;
+$$ LANGUAGE plpgsql;
+
+--
+-- Asserts that the table has the given column. If column_type is given,
+-- the column must also have that type. Type modifiers (such as the length
+-- of a VARCHAR) are not compared.
+--
+CREATE OR REPLACE FUNCTION squire_test.assert_has_column(
+  table_name TEXT, column_name TEXT, column_type TEXT DEFAULT NULL)
+RETURNS VOID AS $$
+DECLARE
+  l_type REGTYPE;
+BEGIN
+  SELECT a.atttypid INTO l_type
+  FROM pg_catalog.pg_attribute a
+  WHERE a.attrelid = to_regclass(table_name)
+    AND a.attname = column_name
+    AND a.attnum > 0
+    AND NOT a.attisdropped;
+
+  IF NOT FOUND THEN
+    RAISE EXCEPTION 'assert_has_column failure: table % has no column %',
+      table_name, column_name
+      USING ERRCODE = 'triggered_action_exception';
+  END IF;
+
+  IF column_type IS NOT NULL AND l_type IS DISTINCT FROM to_regtype(column_type) THEN
+    RAISE EXCEPTION 'assert_has_column failure: column %.% has type %, expected %',
+      table_name, column_name, l_type, column_type
+      USING ERRCODE = 'triggered_action_exception';
+  END IF;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,113 @@
+-- Squire test runtime: function mocking.
+--
+-- This is installed by Squire into the test database alongside pgUnit.
+-- Mocks replace the body of an existing function. Since DDL is transactional
+-- in PostgreSQL and every test case runs in a subtransaction that is rolled
+-- back (see runner.sql), mocks are automatically restored after each test.
+-- Mocks may be created in the test case itself or in a pgUnit setup function.
+
+CREATE SCHEMA IF NOT EXISTS squire_test;
+
+-- mocks stores the original definition of every mocked function so that
+-- it can be restored explicitly within a test with restore_function.
+CREATE TABLE squire_test.mocks (
+  proc       REGPROCEDURE PRIMARY KEY,
+  definition TEXT NOT NULL
+);
+
+--
+-- Replaces the body of a function. The function is specified by its
+-- signature, i.e. 'app.current_account(integer)'. The arguments, return
+-- type, volatility, STRICT, SECURITY DEFINER, and SET clauses are
+-- unchanged, so a SECURITY DEFINER function still runs as its owner. The
+-- language of the replacement body defaults to SQL.
+--
+-- Example:
+--
+--   PERFORM squire_test.mock_function('app.utc_now()',
+--     $$ SELECT '2021-01-01T00:00:00Z'::timestamptz $$);
+--
+CREATE OR REPLACE FUNCTION squire_test.mock_function(
+  signature TEXT, body TEXT, lang TEXT DEFAULT 'sql')
+RETURNS VOID AS $$
+DECLARE
+  l_oid REGPROCEDURE := signature::REGPROCEDURE;
+  l_proc RECORD;
+  l_attrs TEXT;
+  l_config TEXT;
+  l_name TEXT;
+  l_value TEXT;
+BEGIN
+  SELECT p.prokind, n.nspname, p.proname,
+      pg_catalog.pg_get_function_arguments(p.oid) AS args,
+      pg_catalog.pg_get_function_result(p.oid) AS result,
+      p.provolatile, p.proisstrict, p.prosecdef, p.proconfig
+    INTO l_proc
+    FROM pg_catalog.pg_proc p
+    JOIN pg_catalog.pg_namespace n ON n.oid = p.pronamespace
+    WHERE p.oid = l_oid;
+
+  IF l_proc.prokind <> 'f' THEN
+    RAISE EXCEPTION 'mock_function: % is not a function', signature;
+  END IF;
+
+  -- Store the original definition. If the function is mocked more than
+  -- once, we keep the first (real) definition.
+  INSERT INTO squire_test.mocks (proc, definition)
+    VALUES (l_oid, pg_catalog.pg_get_functiondef(l_oid))
+    ON CONFLICT DO NOTHING;
+
+  -- CREATE OR REPLACE resets every attribute that isn't given, so the
+  -- attributes of the original function are repeated.
+  l_attrs := CASE l_proc.provolatile
+    WHEN 'i' THEN 'IMMUTABLE'
+    WHEN 's' THEN 'STABLE'
+    ELSE 'VOLATILE'
+  END;
+  IF l_proc.proisstrict THEN
+    l_attrs := l_attrs || ' STRICT';
+  END IF;
+  IF l_proc.prosecdef THEN
+    l_attrs := l_attrs || ' SECURITY DEFINER';
+  END IF;
+
+  -- Settings are stored as "name=value". List settings such as
+  -- search_path are stored as SQL already, i.e. '"$user", public', and
+  -- must not be quoted again.
+  FOREACH l_config IN ARRAY COALESCE(l_proc.proconfig, '{}') LOOP
+    l_name := split_part(l_config, '=', 1);
+    l_value := substr(l_config, length(l_name) + 2);
+    IF l_name IN ('search_path', 'temp_tablespaces', 'local_preload_libraries',
+        'session_preload_libraries', 'shared_preload_libraries') THEN
+      l_attrs := l_attrs || format(' SET %s TO %s', l_name, l_value);
+    ELSE
+      l_attrs := l_attrs || format(' SET %s TO %L', l_name, l_value);
+    END IF;
+  END LOOP;
+
+  EXECUTE format('CREATE OR REPLACE FUNCTION %I.%I(%s) RETURNS %s LANGUAGE %I %s AS %L',
+    l_proc.nspname, l_proc.proname, l_proc.args, l_proc.result, lang, l_attrs, body);
+END;
+$$ LANGUAGE plpgsql;
+
+--
+-- Restores the original body of a mocked function. This is only necessary
+-- to restore a function in the middle of a test, mocks are restored
+-- automatically at the end of every test.
+--
+CREATE OR REPLACE FUNCTION squire_test.restore_function(signature TEXT)
+RETURNS VOID AS $$
+DECLARE
+  l_definition TEXT;
+BEGIN
+  DELETE FROM squire_test.mocks
+    WHERE proc = signature::REGPROCEDURE
+    RETURNING definition INTO l_definition;
+
+  IF l_definition IS NULL THEN
+    RAISE EXCEPTION 'restore_function: % is not mocked', signature;
+  END IF;
+
+  EXECUTE l_definition;
+END;
+$$ LANGUAGE plpgsql;
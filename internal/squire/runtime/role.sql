@@ -0,0 +1,52 @@
+-- Squire test runtime: roles.
+--
+-- This is installed by Squire into the test database alongside pgUnit.
+-- These functions make it easy to test row-level security policies by
+-- running parts of a test as another role. Test cases can also be run
+-- entirely as another role with the "-- squire:test-role" annotation, which
+-- the test runner applies using these functions.
+
+CREATE SCHEMA IF NOT EXISTS squire_test;
+
+-- test_roles is populated by Squire from the "-- squire:test-role"
+-- annotations on test cases.
+CREATE TABLE squire_test.test_roles (
+  test_name TEXT PRIMARY KEY,
+  role_name TEXT NOT NULL,
+  settings  JSONB NOT NULL DEFAULT '{}'
+);
+
+-- Tests running as other roles need to be able to call the pgUnit and
+-- Squire test functions.
+GRANT USAGE ON SCHEMA pgunit, squire_test TO PUBLIC;
+
+--
+-- Switches the current role for the remainder of the transaction (or
+-- until reset_role is called). Settings are set for the remainder of the
+-- transaction as well and are typically JWT-style request settings, i.e.
+-- '{"request.jwt.claim.sub": "42"}'.
+--
+CREATE OR REPLACE FUNCTION squire_test.set_role(
+  role_name TEXT, settings JSONB DEFAULT '{}')
+RETURNS VOID AS $$
+DECLARE
+  l_key TEXT;
+  l_value TEXT;
+BEGIN
+  FOR l_key, l_value IN SELECT key, value FROM jsonb_each_text(settings) LOOP
+    PERFORM set_config(l_key, l_value, true);
+  END LOOP;
+
+  EXECUTE format('SET LOCAL ROLE %I', role_name);
+END;
+$$ LANGUAGE plpgsql;
+
+--
+-- Switches back to the original role.
+--
+CREATE OR REPLACE FUNCTION squire_test.reset_role()
+RETURNS VOID AS $$
+BEGIN
+  RESET ROLE;
+END;
+$$ LANGUAGE plpgsql;
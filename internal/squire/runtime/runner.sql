@@ -0,0 +1,124 @@
+-- Squire test runtime: test runner.
+--
+-- This is installed by Squire into the test database after pgUnit. It
+-- replaces pgunit.test_run_all() with a runner that runs every test case
+-- inside a subtransaction that is always rolled back, so that tests cannot
+-- leak state into each other regardless of execution order. The pgUnit naming
+-- conventions for setup, precondition, postcondition, and teardown functions
+-- are all supported. Tests annotated with a role (see role.sql) run as that
+-- role, while setup and teardown run as the original role.
+
+CREATE SCHEMA IF NOT EXISTS squire_test;
+
+-- setups is populated by Squire with the statements from the "_setup.sql"
+-- files that apply to each test case (the files in the same "NN-" directory).
+CREATE TABLE squire_test.setups (
+  test_name  TEXT PRIMARY KEY,
+  statements TEXT[] NOT NULL
+);
+
+--
+-- Use select * from squire_test.run_all() to execute all test cases.
+--
+CREATE OR REPLACE FUNCTION squire_test.run_all()
+RETURNS SETOF pgunit.test_results AS $$
+DECLARE
+  l_proc RECORD;
+  l_row pgunit.test_results;
+  l_start_ts TIMESTAMP;
+  l_condition TEXT;
+  l_setup TEXT[];
+  l_stmt TEXT;
+  l_role RECORD;
+BEGIN
+  FOR l_proc IN SELECT p.proname, n.nspname
+      FROM pg_catalog.pg_proc p
+      JOIN pg_catalog.pg_namespace n ON p.pronamespace = n.oid
+      WHERE p.proname LIKE 'test/_case/_%' ESCAPE '/'
+      ORDER BY p.proname LOOP
+    l_row := NULL;
+    l_row.test_name := quote_ident(l_proc.proname);
+
+    -- The outer block is a subtransaction that we always roll back by
+    -- raising squire_test_rollback at the end.
+    BEGIN
+      -- Directory setup files
+      SELECT statements INTO l_setup
+        FROM squire_test.setups WHERE test_name = l_proc.proname;
+      IF l_setup IS NOT NULL THEN
+        FOREACH l_stmt IN ARRAY l_setup LOOP
+          EXECUTE l_stmt;
+        END LOOP;
+      END IF;
+
+      -- pgUnit setup function
+      l_condition := pgunit.test_get_procname(l_proc.proname, 2, 'test_setup');
+      IF l_condition IS NOT NULL THEN
+        EXECUTE format('SELECT %I.%I()', l_proc.nspname, l_condition);
+      END IF;
+
+      -- Switch roles if the test is annotated with a role
+      SELECT role_name, settings INTO l_role
+        FROM squire_test.test_roles WHERE test_name = l_proc.proname;
+      IF FOUND THEN
+        PERFORM squire_test.set_role(l_role.role_name, l_role.settings);
+      END IF;
+
+      -- Execute the test
+      l_start_ts := clock_timestamp();
+      BEGIN
+        l_condition := pgunit.test_get_procname(l_proc.proname, 2, 'test_precondition');
+        IF l_condition IS NOT NULL THEN
+          PERFORM pgunit.test_run_condition(
+            quote_ident(l_proc.nspname) || '.' || quote_ident(l_condition));
+        END IF;
+
+        EXECUTE format('SELECT %I.%I()', l_proc.nspname, l_proc.proname);
+
+        l_condition := pgunit.test_get_procname(l_proc.proname, 2, 'test_postcondition');
+        IF l_condition IS NOT NULL THEN
+          PERFORM pgunit.test_run_condition(
+            quote_ident(l_proc.nspname) || '.' || quote_ident(l_condition));
+        END IF;
+
+        l_row.successful := true;
+        l_row.failed := false;
+        l_row.erroneous := false;
+        l_row.error_message := 'OK';
+      EXCEPTION
+        WHEN triggered_action_exception THEN
+          l_row.successful := false;
+          l_row.failed := true;
+          l_row.erroneous := false;
+          l_row.error_message := SQLERRM;
+        WHEN others THEN
+          l_row.successful := false;
+          l_row.failed := false;
+          l_row.erroneous := true;
+          l_row.error_message := SQLERRM;
+      END;
+      l_row.duration := clock_timestamp() - l_start_ts;
+      PERFORM squire_test.reset_role();
+
+      -- pgUnit teardown function
+      l_condition := pgunit.test_get_procname(l_proc.proname, 2, 'test_teardown');
+      IF l_condition IS NOT NULL THEN
+        EXECUTE format('SELECT %I.%I()', l_proc.nspname, l_condition);
+      END IF;
+
+      RAISE EXCEPTION 'squire_test_rollback' USING ERRCODE = 'SQRB0';
+    EXCEPTION
+      WHEN SQLSTATE 'SQRB0' THEN
+        NULL;
+      WHEN others THEN
+        -- An error outside of the test itself, i.e. in setup or teardown.
+        l_row.successful := false;
+        l_row.failed := false;
+        l_row.erroneous := true;
+        l_row.error_message := 'error in setup or teardown: ' || SQLERRM;
+    END;
+
+    RETURN NEXT l_row;
+  END LOOP;
+END;
+$$ LANGUAGE plpgsql;
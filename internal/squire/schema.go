@@ -23,16 +23,9 @@ type SchemaOptions struct {
 // Schema generates the SQL schema from the SQL directory in the attached
 // configuration on the Squire instance.
 func (s *Squire) Schema(opts *SchemaOptions) error {
-	// Determine our directory. We want an absolute directory so we can
-	// put together the fs.FS implementation.
-	sqlDir, err := filepath.Abs(s.config.SQLDir)
+	rootDir, rootFile, err := s.sqlRoot()
 	if err != nil {
-		return fmt.Errorf("Error expanding sql directory: %w", err)
-	}
-	rootDir, rootFile := filepath.Split(sqlDir)
-	if len(rootDir) > 0 && rootDir[len(rootDir)-1] == filepath.Separator {
-		// Strip the trailining filepath separator.
-		rootDir = rootDir[:len(rootDir)-1]
+		return err
 	}
 
 	// Build to our output
@@ -48,3 +41,22 @@ func (s *Squire) Schema(opts *SchemaOptions) error {
 		},
 	})
 }
+
+// sqlRoot returns the absolute parent directory of the SQL directory
+// and the name of the SQL directory within it. This is the form that
+// sqlbuild expects so it can use an fs.FS implementation.
+func (s *Squire) sqlRoot() (string, string, error) {
+	// Determine our directory. We want an absolute directory so we can
+	// put together the fs.FS implementation.
+	sqlDir, err := filepath.Abs(s.config.SQLDir)
+	if err != nil {
+		return "", "", fmt.Errorf("Error expanding sql directory: %w", err)
+	}
+	rootDir, rootFile := filepath.Split(sqlDir)
+	if len(rootDir) > 0 && rootDir[len(rootDir)-1] == filepath.Separator {
+		// Strip the trailining filepath separator.
+		rootDir = rootDir[:len(rootDir)-1]
+	}
+
+	return rootDir, rootFile, nil
+}
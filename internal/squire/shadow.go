@@ -0,0 +1,210 @@
+package squire
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/mitchellh/squire/internal/dbcontainer"
+	"github.com/mitchellh/squire/internal/pkg/stdcapture"
+)
+
+// scratchDB is a temporary database used for diffing, verification, and
+// tests. It is either a shadow database: a separate logical database within
+// the already running dev container, a clone leased from the pool, or a
+// new clone of the dev container.
+//
+// Shadow databases and pool clones are much faster since they don't require
+// starting a container. New clones are used as a fallback.
+type scratchDB struct {
+	dbcontainer.Container
+
+	// shadow is true if this is a shadow database within the dev container.
+	shadow bool
+
+	// lease is non-nil if this is a clone leased from the pool.
+	lease *dbcontainer.Lease
+}
+
+// shadowDB returns a new shadow database for the given purpose within
+// the base container. The database is named
+// "squire_shadow_<purpose>_<unix nanoseconds>" so that concurrent commands
+// never share a shadow database, and so that GC can tell how old one that
+// was left behind is. The base container must be running before calling Up.
+//
+// For the external backend, a clone is used instead. Clones are databases
+// on the same server so they're just as fast, and they're named after the
+// dev database so they don't conflict with other projects on the server.
+func shadowDB(base dbcontainer.Container, purpose string) (*scratchDB, error) {
+	if _, ok := base.(*dbcontainer.External); ok {
+		return cloneDB(base, scratchName("shadow-"+purpose))
+	}
+
+	ctr, err := base.Database(fmt.Sprintf(
+		"%s%s_%d", shadowPrefix, purpose, time.Now().UnixNano()))
+	if err != nil {
+		return nil, err
+	}
+
+	return &scratchDB{Container: ctr, shadow: true}, nil
+}
+
+// shadowCreated returns when the shadow database with the given name was
+// created, from the suffix added by shadowDB. This returns false if the
+// name isn't that of a shadow database.
+func shadowCreated(name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, shadowPrefix) {
+		return time.Time{}, false
+	}
+
+	idx := strings.LastIndexByte(name, '_')
+	n, err := strconv.ParseInt(name[idx+1:], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(0, n), true
+}
+
+// scratchName returns a unique name for a clone for the given purpose.
+// Clones with a numeric suffix are collected by GC (see
+// dbcontainer.CloneInfo.Ephemeral).
+func scratchName(purpose string) string {
+	return fmt.Sprintf("%s-%d", purpose, time.Now().UnixNano())
+}
+
+// cloneDB returns a clone of the base container with the given name.
+func cloneDB(base dbcontainer.Container, n string) (*scratchDB, error) {
+	ctr, err := base.Clone(n)
+	if err != nil {
+		return nil, err
+	}
+
+	return &scratchDB{Container: ctr}, nil
+}
+
+// poolDB leases a clone of the base container from the pool. If the pool
+// is disabled, exhausted, or can't be used for any other reason (i.e. a
+// pool container fails to start), this returns nil so that the caller
+// falls back to a shadow database or a new clone. The pool is only an
+// optimization so its errors are logged rather than returned.
+func (s *Squire) poolDB(ctx context.Context, base dbcontainer.Container) *scratchDB {
+	size := s.config.Dev.PoolSize
+	if size == 0 {
+		return nil
+	}
+
+	// We need to capture stdout/stderr because the compose API doesn't
+	// allow configurable output streams. The pool is filled on lease.
+	var lease *dbcontainer.Lease
+	err := stdcapture.SuccessOnly(ioutil.Discard, ioutil.Discard, func() error {
+		var err error
+		lease, err = base.Pool(size).Lease(ctx)
+		return err
+	})
+	if errors.Is(err, dbcontainer.ErrPoolExhausted) {
+		s.logger.Named("pool").Info("pool exhausted, not using the pool", "size", size)
+		return nil
+	}
+	if err != nil {
+		s.logger.Named("pool").Warn("error leasing from the pool, not using the pool", "err", err)
+		return nil
+	}
+
+	return &scratchDB{Container: lease.Container, lease: lease}
+}
+
+// scratchDBFor returns a clone leased from the pool if the pool is
+// enabled, a shadow database within the base container if it is running,
+// or a new clone of the base container otherwise.
+func (s *Squire) scratchDBFor(
+	ctx context.Context,
+	base dbcontainer.Container,
+	purpose string,
+) (*scratchDB, error) {
+	if d := s.poolDB(ctx, base); d != nil {
+		return d, nil
+	}
+
+	st, err := base.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if st.State == dbcontainer.Running {
+		return shadowDB(base, purpose)
+	}
+
+	return cloneDB(base, scratchName(purpose))
+}
+
+// Up creates the scratch database. For a shadow database, this (re)creates
+// the empty database. For a new clone, this starts the container. Pool
+// clones are already running so this does nothing.
+func (d *scratchDB) Up(ctx context.Context) error {
+	if d.lease != nil {
+		return nil
+	}
+	if d.shadow {
+		return recreateDB(ctx, d.ConnURI())
+	}
+
+	// We need to capture stdout/stderr because the compose API doesn't
+	// allow configurable output streams.
+	return stdcapture.SuccessOnly(ioutil.Discard, ioutil.Discard, func() error {
+		return d.Container.Up(ctx)
+	})
+}
+
+// Destroy destroys the scratch database. For a shadow database, this drops
+// the database and leaves the dev container running. Pool clones are
+// returned to the pool. For a new clone, this destroys the container.
+//
+// This doesn't take a context because it is usually called once the
+// operation is over, and the operation's context may be cancelled (i.e.
+// on interrupt). A context with a timeout is used instead, see
+// cleanupContext.
+func (d *scratchDB) Destroy() error {
+	ctx, cancel := cleanupContext()
+	defer cancel()
+
+	if d.lease != nil {
+		return d.lease.Return()
+	}
+	if d.shadow {
+		db, dbname, err := adminDB(ctx, d.ConnURI())
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		_, err = db.ExecContext(ctx,
+			"DROP DATABASE IF EXISTS "+pgx.Identifier{dbname}.Sanitize()+" WITH (FORCE)")
+		return err
+	}
+
+	return stdcapture.SuccessOnly(ioutil.Discard, ioutil.Discard, func() error {
+		return d.Container.Down(ctx)
+	})
+}
+
+// cleanupContext returns a context for destroying temporary databases and
+// containers. This is not derived from any other context so that cleanup
+// still happens after an interrupt, but it has a timeout so that cleanup
+// can't hang forever.
+func cleanupContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), cleanupTimeout)
+}
+
+const (
+	// shadowPrefix is the prefix of the name of all shadow databases.
+	shadowPrefix = "squire_shadow_"
+
+	// cleanupTimeout is the maximum time to wait for cleanup.
+	cleanupTimeout = 1 * time.Minute
+)
@@ -0,0 +1,138 @@
+package squire
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mitchellh/squire/internal/config"
+	"github.com/mitchellh/squire/internal/dbcontainer"
+)
+
+func TestShadowDB(t *testing.T) {
+	require := require.New(t)
+
+	cfg, err := config.New(config.FromString(`sql_dir: "testdata/diff-1"`))
+	require.NoError(err)
+	sq, err := New(WithConfig(cfg))
+	require.NoError(err)
+	ctr, err := sq.Container()
+	require.NoError(err)
+
+	d, err := shadowDB(ctr, "diff")
+	require.NoError(err)
+	require.True(d.shadow)
+
+	// Same server, different database
+	u, err := url.Parse(ctr.ConnURI())
+	require.NoError(err)
+	u2, err := url.Parse(d.ConnURI())
+	require.NoError(err)
+	require.Equal(u.Host, u2.Host)
+	require.True(strings.HasPrefix(u2.Path, "/squire_shadow_diff_"))
+	require.Equal(ctr.Name(), d.Name())
+
+	// Every shadow database is new
+	d2, err := shadowDB(ctr, "diff")
+	require.NoError(err)
+	require.NotEqual(d.ConnURI(), d2.ConnURI())
+
+	// The creation time is in the name
+	created, ok := shadowCreated(strings.TrimPrefix(u2.Path, "/"))
+	require.True(ok)
+	require.WithinDuration(time.Now(), created, time.Minute)
+	_, ok = shadowCreated("squire_shadow_diff")
+	require.False(ok)
+	_, ok = shadowCreated("squire")
+	require.False(ok)
+}
+
+func TestShadowDB_lifecycle(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	cfg, err := config.New(config.FromString(`sql_dir: "testdata/diff-1"`))
+	require.NoError(err)
+	sq, err := New(WithConfig(cfg))
+	require.NoError(err)
+	ctr, err := sq.Container()
+	require.NoError(err)
+
+	// Spin up the container
+	require.NoError(ctr.Up(ctx))
+	defer ctr.Down(ctx)
+
+	// Create
+	d, err := shadowDB(ctr, "diff")
+	require.NoError(err)
+	require.NoError(d.Up(ctx))
+	shadows, err := testShadowDatabases(ctr)
+	require.NoError(err)
+	require.Equal([]string{testDatabaseName(t, d)}, shadows)
+
+	// Destroy
+	require.NoError(d.Destroy())
+	shadows, err = testShadowDatabases(ctr)
+	require.NoError(err)
+	require.Empty(shadows)
+
+	// A shadow database that was left behind is collected
+	d, err = shadowDB(ctr, "diff")
+	require.NoError(err)
+	require.NoError(d.Up(ctx))
+
+	var collected []string
+	require.NoError(sq.GC(ctx, &GCOptions{
+		Container: ctr,
+		Callback: func(info *dbcontainer.CloneInfo) {
+			collected = append(collected, info.Project)
+		},
+	}))
+	require.Equal([]string{testDatabaseName(t, d)}, collected)
+	shadows, err = testShadowDatabases(ctr)
+	require.NoError(err)
+	require.Empty(shadows)
+}
+
+// testShadowDatabases returns the names of all the shadow databases in
+// the running container.
+func testShadowDatabases(ctr dbcontainer.Container) ([]string, error) {
+	db, _, err := adminDB(context.Background(), ctr.ConnURI())
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(
+		"SELECT datname FROM pg_catalog.pg_database WHERE starts_with(datname, $1)",
+		shadowPrefix)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+
+		result = append(result, name)
+	}
+
+	return result, rows.Err()
+}
+
+// testDatabaseName returns the name of the logical database of ctr.
+func testDatabaseName(t *testing.T, ctr dbcontainer.Container) string {
+	t.Helper()
+
+	u, err := url.Parse(ctr.ConnURI())
+	require.NoError(t, err)
+	return strings.TrimPrefix(u.Path, "/")
+}
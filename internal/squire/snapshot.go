@@ -0,0 +1,218 @@
+package squire
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/mitchellh/squire/internal/sqlbuild"
+)
+
+// testSnapshots runs the query result snapshot tests (files ending in
+// "_snapshot.sql") and compares the results to the golden files alongside
+// them. The golden files are in the given format ("csv" or "json"). If update
+// is true, the golden files are written rather than compared.
+func (s *Squire) testSnapshots(
+	ctx context.Context,
+	db *sql.DB,
+	format string,
+	update bool,
+) ([]*GoldenResult, error) {
+	L := s.logger.Named("snapshot")
+
+	if format == "" {
+		format = snapshotCSV
+	}
+	if format != snapshotCSV && format != snapshotJSON {
+		return nil, errors.Newf("invalid snapshot format: %q", format)
+	}
+
+	rootDir, rootFile, err := s.sqlRoot()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := sqlbuild.Files(os.DirFS(rootDir), rootFile, sqlbuild.SnapshotSuffix, L)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*GoldenResult
+	for _, f := range files {
+		L := L.With("file", f)
+		L.Debug("running snapshot test")
+
+		r := &GoldenResult{
+			File:   f,
+			Golden: goldenPath(f, format),
+		}
+		result = append(result, r)
+
+		src, err := ioutil.ReadFile(filepath.Join(rootDir, f))
+		if err != nil {
+			return nil, err
+		}
+
+		sets, err := queryAll(ctx, db, string(src))
+		if err != nil {
+			return nil, fmt.Errorf("error running queries in %s: %w", f, err)
+		}
+
+		var actual []byte
+		switch format {
+		case snapshotJSON:
+			actual, err = json.MarshalIndent(sets, "", "  ")
+			actual = append(actual, '\n')
+		default:
+			actual, err = snapshotCSVBytes(sets)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if err := checkGolden(rootDir, r, actual, update); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// resultSet is the result of a single query in a snapshot test.
+type resultSet struct {
+	Query   string          `json:"query"`
+	Columns []string        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// queryAll runs every statement in src within a single transaction and
+// returns the results of every statement that returns rows. The transaction
+// is always rolled back so snapshot files may insert their own data.
+func queryAll(ctx context.Context, db *sql.DB, src string) ([]*resultSet, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var result []*resultSet
+	for _, stmt := range splitStatements(src) {
+		set, err := querySet(ctx, tx, stmt)
+		if err != nil {
+			return nil, err
+		}
+		if set != nil {
+			result = append(result, set)
+		}
+	}
+
+	return result, nil
+}
+
+// querySet runs a single statement and returns its result set. If the
+// statement doesn't return any columns, nil is returned.
+func querySet(ctx context.Context, tx *sql.Tx, stmt string) (*resultSet, error) {
+	rows, err := tx.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if len(cols) == 0 {
+		return nil, rows.Close()
+	}
+
+	set := &resultSet{
+		Query:   stmt,
+		Columns: cols,
+		Rows:    [][]interface{}{},
+	}
+	for rows.Next() {
+		vals := make([]interface{}, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+
+		for i, v := range vals {
+			vals[i] = snapshotValue(v)
+		}
+		set.Rows = append(set.Rows, vals)
+	}
+
+	return set, rows.Err()
+}
+
+// snapshotValue normalizes a scanned value so that it has a stable
+// representation in golden files.
+func snapshotValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return v
+	}
+}
+
+// snapshotCSVBytes renders the result sets in CSV format. Each result set
+// is preceded by its query and separated by a blank line. NULL values are
+// rendered as "NULL".
+func snapshotCSVBytes(sets []*resultSet) ([]byte, error) {
+	var buf bytes.Buffer
+	for i, set := range sets {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(&buf, "%s;\n\n", set.Query)
+
+		w := csv.NewWriter(&buf)
+		if err := w.Write(set.Columns); err != nil {
+			return nil, err
+		}
+		for _, row := range set.Rows {
+			record := make([]string, len(row))
+			for i, v := range row {
+				if v == nil {
+					record[i] = "NULL"
+					continue
+				}
+
+				record[i] = fmt.Sprint(v)
+			}
+
+			if err := w.Write(record); err != nil {
+				return nil, err
+			}
+		}
+
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+const (
+	snapshotCSV  = "csv"
+	snapshotJSON = "json"
+)
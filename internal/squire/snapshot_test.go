@@ -0,0 +1,38 @@
+package squire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotCSVBytes(t *testing.T) {
+	require := require.New(t)
+
+	actual, err := snapshotCSVBytes([]*resultSet{
+		{
+			Query:   "SELECT id, name FROM accounts",
+			Columns: []string{"id", "name"},
+			Rows: [][]interface{}{
+				{int64(1), "alice, bob"},
+				{int64(2), nil},
+			},
+		},
+		{
+			Query:   "SELECT 1 AS one",
+			Columns: []string{"one"},
+			Rows:    [][]interface{}{},
+		},
+	})
+	require.NoError(err)
+	require.Equal(`SELECT id, name FROM accounts;
+
+id,name
+1,"alice, bob"
+2,NULL
+
+SELECT 1 AS one;
+
+one
+`, string(actual))
+}
@@ -10,6 +10,11 @@ import (
 type Squire struct {
 	logger hclog.Logger
 	config *config.Config
+
+	// database and service are set for one of multiple databases in a
+	// project. See Database.
+	database string
+	service  string
 }
 
 // Option is used to create a new Squire.
@@ -0,0 +1,91 @@
+package squire
+
+import (
+	"regexp"
+	"strings"
+)
+
+// splitStatements splits a string of SQL into individual statements.
+// Statements are separated by semicolons that are not within quotes,
+// dollar-quoted strings, or comments. The returned statements are trimmed
+// and do not include the trailing semicolon. Statements that contain only
+// whitespace and comments are not returned.
+//
+// This is not a full SQL parser, but it handles the lexical structures
+// that can contain a semicolon that doesn't terminate a statement.
+func splitStatements(src string) []string {
+	var result []string
+
+	start := 0
+	content := false
+	emit := func(end int) {
+		if content {
+			result = append(result, strings.TrimSpace(src[start:end]))
+		}
+
+		start = end + 1
+		content = false
+	}
+
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '-' && strings.HasPrefix(src[i:], "--"):
+			// Line comment, skip to the end of line
+			if idx := strings.IndexByte(src[i:], '\n'); idx >= 0 {
+				i += idx
+			} else {
+				i = len(src)
+			}
+
+		case c == '/' && strings.HasPrefix(src[i:], "/*"):
+			// Block comment, skip to the end of it
+			if idx := strings.Index(src[i+2:], "*/"); idx >= 0 {
+				i += idx + 3
+			} else {
+				i = len(src)
+			}
+
+		case c == '\'' || c == '"':
+			// Quoted string or identifier. Escaped quotes are doubled so
+			// we can treat them as two adjacent strings.
+			content = true
+			if idx := strings.IndexByte(src[i+1:], c); idx >= 0 {
+				i += idx + 1
+			} else {
+				i = len(src)
+			}
+
+		case c == '$':
+			// Possibly a dollar-quoted string
+			content = true
+			tag := reDollarTag.FindString(src[i:])
+			if tag == "" {
+				continue
+			}
+
+			if idx := strings.Index(src[i+len(tag):], tag); idx >= 0 {
+				i += len(tag) + idx + len(tag) - 1
+			} else {
+				i = len(src)
+			}
+
+		case c == ';':
+			emit(i)
+
+		case c != ' ' && c != '\t' && c != '\n' && c != '\r':
+			content = true
+		}
+	}
+
+	// Any trailing statement without a semicolon
+	if start < len(src) {
+		emit(len(src))
+	}
+
+	return result
+}
+
+// reDollarTag matches the opening tag of a dollar-quoted string, such
+// as "$$" or "$body$".
+var reDollarTag = regexp.MustCompile(`^\$(?:[A-Za-z_][A-Za-z0-9_]*)?\$`)
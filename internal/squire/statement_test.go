@@ -0,0 +1,69 @@
+package squire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitStatements(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Input    string
+		Expected []string
+	}{
+		{
+			"empty",
+			"",
+			nil,
+		},
+
+		{
+			"single no semicolon",
+			"SELECT 1",
+			[]string{"SELECT 1"},
+		},
+
+		{
+			"multiple",
+			"SELECT 1;\nSELECT 2;\n",
+			[]string{"SELECT 1", "SELECT 2"},
+		},
+
+		{
+			"comments only",
+			"-- hello;\n/* world; */\n",
+			nil,
+		},
+
+		{
+			"comment before statement",
+			"-- find accounts; fast\nSELECT 1;",
+			[]string{"-- find accounts; fast\nSELECT 1"},
+		},
+
+		{
+			"quoted semicolons",
+			`SELECT ';', "a;b" FROM x; SELECT 'it''s;'`,
+			[]string{`SELECT ';', "a;b" FROM x`, `SELECT 'it''s;'`},
+		},
+
+		{
+			"dollar quoted",
+			"DO $body$ BEGIN PERFORM 1; END; $body$; SELECT $$;$$",
+			[]string{"DO $body$ BEGIN PERFORM 1; END; $body$", "SELECT $$;$$"},
+		},
+
+		{
+			"positional parameters",
+			"PREPARE q AS SELECT $1; SELECT 2",
+			[]string{"PREPARE q AS SELECT $1", "SELECT 2"},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			require.Equal(t, tt.Expected, splitStatements(tt.Input))
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package squire
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+
+	"github.com/mitchellh/squire/internal/dbcontainer"
+)
+
+type StatusOptions struct {
+	// Container is the primary dev container. If this is nil, the default
+	// Container is used.
+	Container dbcontainer.Container
+
+	// ProductionURI is the connection address of production. If this is
+	// set, production is checked for changes since the last recorded
+	// deployment (see RecordDeployment).
+	ProductionURI string
+}
+
+// Status is the status of a project's databases, see Squire.Status.
+//
+// The checks other than the container status don't prevent each other
+// from running, so their errors are recorded here rather than returned.
+type Status struct {
+	// Container is the status of the dev container.
+	Container *dbcontainer.Status
+
+	// SchemaCurrent is true if the dev database has the schema of the
+	// current SQL build. This is only checked if the dev container is
+	// running, and is only valid if SchemaErr is nil.
+	SchemaCurrent bool
+	SchemaErr     error
+
+	// Deployment is the last recorded deployment to production, or nil
+	// if there is none.
+	Deployment *Deployment
+
+	// Drifted is true if the production schema changed since Deployment.
+	// This is only checked if ProductionURI is set and a deployment is
+	// recorded, and is only valid if DriftErr is nil.
+	Drifted  bool
+	DriftErr error
+
+	// Clones are the clones of the dev container that were created for a
+	// single operation and are still around. These are either in use by a
+	// running Squire process or were left behind, see GC.
+	Clones []*dbcontainer.CloneInfo
+}
+
+// Status returns the status of the dev container, whether its schema is
+// current, whether production has drifted, and the clones that may have
+// been left behind.
+//
+// The schema is current if the last Reset of the dev database applied the
+// same SQL as the current build (see recordBuildHash). Changes made to the
+// dev database by other means aren't detected.
+func (s *Squire) Status(ctx context.Context, opts *StatusOptions) (*Status, error) {
+	L := s.logger.Named("status")
+
+	var err error
+	if opts.Container == nil {
+		opts.Container, err = s.Container()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var result Status
+	result.Container, err = opts.Container.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Container.State == dbcontainer.Running {
+		L.Debug("checking the schema of the dev database")
+		result.SchemaCurrent, result.SchemaErr = s.schemaCurrent(ctx, opts.Container)
+	}
+
+	result.Deployment, err = s.LastDeployment()
+	if err != nil {
+		return nil, err
+	}
+	if opts.ProductionURI != "" && result.Deployment != nil {
+		L.Debug("checking production for drift")
+		result.Drifted, result.DriftErr = s.Drifted(
+			ctx, opts.ProductionURI, result.Deployment)
+	}
+
+	clones, err := opts.Container.Clones(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result.Clones = gcFilter(clones, &GCOptions{}, time.Now())
+
+	return &result, nil
+}
+
+// schemaCurrent returns true if the SQL build recorded in the database of
+// ctr by Reset (see recordBuildHash) is the current SQL build.
+func (s *Squire) schemaCurrent(ctx context.Context, ctr dbcontainer.Container) (bool, error) {
+	hash, err := s.buildHash()
+	if err != nil {
+		return false, err
+	}
+
+	db, err := ctr.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer db.Close()
+
+	var recorded sql.NullString
+	if err := db.QueryRowContext(ctx,
+		"SELECT current_setting($1, true)", buildHashSetting,
+	).Scan(&recorded); err != nil {
+		return false, err
+	}
+
+	return recorded.String == hash, nil
+}
+
+// recordBuildHash records the hash of the SQL build (see buildHash) that
+// was applied to the database of ctr as a setting of the database. The
+// setting is removed along with the database when it is recreated, so a
+// database that wasn't reset with the SQL build has no hash.
+func recordBuildHash(ctx context.Context, ctr dbcontainer.Container, hash string) error {
+	db, err := ctr.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var dbname string
+	if err := db.QueryRowContext(ctx, "select current_database()").Scan(&dbname); err != nil {
+		return err
+	}
+
+	// The hash is hex so it doesn't need to be escaped.
+	_, err = db.ExecContext(ctx, "ALTER DATABASE "+pgx.Identifier{dbname}.Sanitize()+
+		" SET "+buildHashSetting+" = '"+hash+"'")
+	return err
+}
+
+// buildHash returns a hash of the SQL build (see Schema). Only the SQL
+// statements are hashed (see sqlStatements) so that the generation time
+// in the header of the build doesn't change the hash.
+func (s *Squire) buildHash() (string, error) {
+	var buf bytes.Buffer
+	if err := s.Schema(&SchemaOptions{Output: &buf}); err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(sqlStatements(buf.Bytes()))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// buildHashSetting is the database setting that has the hash of the SQL
+// build applied by the last Reset, see recordBuildHash.
+const buildHashSetting = "squire.schema_hash"
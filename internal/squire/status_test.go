@@ -0,0 +1,28 @@
+package squire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mitchellh/squire/internal/config"
+)
+
+func TestBuildHash(t *testing.T) {
+	require := require.New(t)
+
+	hash := func(dir string) string {
+		cfg, err := config.New(config.FromString(`sql_dir: "` + dir + `"`))
+		require.NoError(err)
+		sq, err := New(WithConfig(cfg))
+		require.NoError(err)
+
+		h, err := sq.buildHash()
+		require.NoError(err)
+		return h
+	}
+
+	// The same SQL always has the same hash
+	require.Equal(hash("testdata/schema"), hash("testdata/schema"))
+	require.NotEqual(hash("testdata/schema"), hash("testdata/deploy"))
+}
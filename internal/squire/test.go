@@ -5,32 +5,104 @@ import (
 	"context"
 	"database/sql"
 	_ "embed"
-	"io/ioutil"
 	"strings"
 
 	"github.com/cockroachdb/errors"
 
 	"github.com/mitchellh/squire/internal/dbcontainer"
-	"github.com/mitchellh/squire/internal/pkg/stdcapture"
 )
 
 //go:embed vendor/pgunit/pgunit.sql
 var pgUnitSQL []byte
 
+// The Squire test runtime is installed alongside pgUnit. See the
+// comments in each file for details.
+var (
+	//go:embed runtime/runner.sql
+	runnerSQL []byte
+
+	//go:embed runtime/assert.sql
+	assertSQL []byte
+
+	//go:embed runtime/mock.sql
+	mockSQL []byte
+
+	//go:embed runtime/role.sql
+	roleSQL []byte
+)
+
 type TestPGUnitOptions struct {
 	// Container is the primary dev container. If this is nil, the default
 	// Container is used.
-	Container *dbcontainer.Container
+	Container dbcontainer.Container
 
 	// Callback is called with the result of calling pgUnit. This can be
 	// used to inspect the results or render in any way. If this is nil,
 	// the results are discarded.
 	Callback func(*sql.Rows) error
+
+	// Keep, if true, will not destroy the test container when the tests
+	// complete. The test container will remain running with the schema and
+	// pgUnit installed so that it can be used for debugging. The container
+	// can be retrieved later with TestContainer.
+	Keep bool
+
+	// Cover, if true, will track function calls during the test run and
+	// call CoverCallback with the coverage of every user-defined function
+	// after the tests complete. Functions defined in test files are not
+	// included.
+	Cover         bool
+	CoverCallback func([]*FunctionCoverage) error
+
+	// PlanCallback is called with the results of the query plan tests
+	// (files ending in "_plan.sql"). If UpdatePlans is true, the golden
+	// files for the plan tests are written instead of compared. If this
+	// returns an error, the snapshot tests still run and both errors are
+	// returned.
+	PlanCallback func([]*GoldenResult) error
+	UpdatePlans  bool
+
+	// SnapshotCallback is called with the results of the query result
+	// snapshot tests (files ending in "_snapshot.sql"). If UpdateSnapshots
+	// is true, the golden files for the snapshot tests are written instead
+	// of compared.
+	SnapshotCallback func([]*GoldenResult) error
+	UpdateSnapshots  bool
+
+	// Shadow, if true, runs the tests in a new shadow database (named
+	// "squire_shadow_test_<time>") within the running dev container rather
+	// than in a clone of the dev container. For the external backend, this
+	// is a "test-<time>" clone on the same server instead. This is much
+	// faster since no container has to be started, but the test roles are
+	// created in the dev container. The dev container must be running.
+	Shadow bool
+
+	// UpgradeFrom, if set, tests the upgrade path from a previous version
+	// of the schema rather than a fresh schema. This can be a git ref or
+	// a path to a SQL file (such as a schema dump). The previous schema is
+	// applied to the test database, then the diff to the current schema
+	// is generated with Diff and deployed, and then the tests are run.
+	// This requires pgquarrel, just like Diff.
+	UpgradeFrom string
+
+	// database, if set, is the database to run the tests in instead of
+	// creating one. It is recreated for the run but never destroyed, so
+	// that it can be reused across runs by the caller (see Watch).
+	database *scratchDB
 }
 
 // TestPGUnit creates a new test database with the raw schema and then runs
-// the tests against it. This automatically installs pgUnit and runs all
-// tests.
+// the tests against it. This automatically installs pgUnit and the Squire
+// assertions, mocking, and role helpers (in the "squire_test" schema) and
+// runs all tests.
+//
+// Fixture files (ending in "_fixture.sql") are loaded after the schema.
+// Setup files (ending in "_setup.sql") are run prior to each test case
+// defined in the same "NN-" directory. Every test case runs in its own
+// subtransaction that is rolled back afterwards, so tests can't leak state
+// into each other. Test cases annotated with "-- squire:test-role" run as
+// that role. The roles are created from the configuration and from roles
+// files (ending in "_roles.sql") before the schema is applied.
 func (s *Squire) TestPGUnit(ctx context.Context, opts *TestPGUnitOptions) error {
 	L := s.logger.Named("test")
 
@@ -44,38 +116,56 @@ func (s *Squire) TestPGUnit(ctx context.Context, opts *TestPGUnitOptions) error
 	if opts.Callback == nil {
 		opts.Callback = func(*sql.Rows) error { return nil }
 	}
-
-	// We need to create a temporary container to reset onto for the
-	// diffing process.
-	L.Debug("cloning and launching test container")
-	ctr, err := opts.Container.Clone("test")
-	if err != nil {
-		return errors.WithDetail(
-			errors.Newf("error creating test container: %w", err),
-			strings.TrimSpace(errCreatingTestContainer),
-		)
+	if opts.CoverCallback == nil {
+		opts.CoverCallback = func([]*FunctionCoverage) error { return nil }
+	}
+	if opts.PlanCallback == nil {
+		opts.PlanCallback = func([]*GoldenResult) error { return nil }
+	}
+	if opts.SnapshotCallback == nil {
+		opts.SnapshotCallback = func([]*GoldenResult) error { return nil }
 	}
 
-	// We need to capture stdout/stderr because the compose API doesn't
-	// allow configurable output streams.
-	err = stdcapture.SuccessOnly(ioutil.Discard, ioutil.Discard, func() error {
-		return ctr.Up(ctx)
-	})
+	// Create the database we run the tests in.
+	L.Debug("creating test database", "shadow", opts.Shadow)
+	tdb := opts.database
+	if tdb == nil && !opts.Shadow && !opts.Keep {
+		// Kept test databases must be found again later, so they never
+		// come from the pool.
+		tdb = s.poolDB(ctx, opts.Container)
+	}
+	if tdb == nil {
+		tdb, err = s.testDB(ctx, opts.Container, opts.Shadow)
+	}
 	if err != nil {
 		return errors.WithDetail(
-			errors.Newf("error starting test container: %w", err),
+			errors.Newf("error creating test database: %w", err),
 			strings.TrimSpace(errCreatingTestContainer),
 		)
 	}
+	// Destroy is deferred prior to Up so that a partially created test
+	// database is cleaned up as well, i.e. if we're interrupted while starting.
 	defer func() {
-		err = stdcapture.SuccessOnly(ioutil.Discard, ioutil.Discard, func() error {
-			return ctr.Down(ctx)
-		})
-		if err != nil {
-			L.Error("error destroying test container, may still be dangling",
+		if opts.database != nil {
+			return
+		}
+		if opts.Keep {
+			L.Info("keeping test database", "uri", tdb.ConnURI())
+			return
+		}
+
+		if err := tdb.Destroy(); err != nil {
+			L.Error("error destroying test database, may still be dangling",
 				"err", err)
 		}
 	}()
+	if err := tdb.Up(ctx); err != nil {
+		return errors.WithDetail(
+			errors.Newf("error starting test database: %w", err),
+			strings.TrimSpace(errCreatingTestContainer),
+		)
+	}
+	ctr := tdb.Container
 
 	// Build our full schema including tests
 	var buf bytes.Buffer
@@ -88,15 +178,40 @@ func (s *Squire) TestPGUnit(ctx context.Context, opts *TestPGUnitOptions) error
 		return err
 	}
 
-	// Reset on our test container
-	if err := s.Reset(ctx, &ResetOptions{
-		Container: ctr,
-		Schema:    &buf,
-	}); err != nil {
-		return errors.WithDetail(
-			errors.Newf("error applying schema to source container: %w", err),
-			strings.TrimSpace(errCreatingTestContainer),
-		)
+	schema := buf.Bytes()
+
+	// Create the roles for our tests. This must happen before the schema
+	// is applied since the schema may reference them.
+	L.Debug("creating test roles")
+	if err := s.testRoles(ctx, ctr); err != nil {
+		return err
+	}
+
+	if opts.UpgradeFrom != "" {
+		// Upgrade from the previous schema to our current schema
+		if err := s.upgradeTestContainer(ctx, opts.Container, ctr, opts.UpgradeFrom); err != nil {
+			return err
+		}
+	} else {
+		// Reset on our test container
+		if err := s.Reset(ctx, &ResetOptions{
+			Container: ctr,
+			Schema:    bytes.NewReader(schema),
+		}); err != nil {
+			return errors.WithDetail(
+				errors.Newf("error applying schema to source container: %w", err),
+				strings.TrimSpace(errCreatingTestContainer),
+			)
+		}
+	}
+
+	// Enable function tracking for coverage. This must happen before we
+	// connect since it only applies to new sessions.
+	if opts.Cover {
+		L.Debug("enabling function tracking for coverage")
+		if err := enableCoverage(ctx, ctr); err != nil {
+			return errors.Newf("error enabling coverage: %w", err)
+		}
 	}
 
 	// Connect to our database
@@ -107,25 +222,182 @@ func (s *Squire) TestPGUnit(ctx context.Context, opts *TestPGUnitOptions) error
 	}
 	defer db.Close()
 
-	// Initialize pgUnit
+	// Initialize pgUnit. pgUnit changes the search_path of the session
+	// that installs it so we use a dedicated connection.
 	L.Debug("deploying pgUnit")
 	if err := s.Deploy(ctx, &DeployOptions{
-		SQL:    bytes.NewReader(pgUnitSQL),
-		Target: db,
+		SQL:       bytes.NewReader(pgUnitSQL),
+		TargetURI: ctr.ConnURI(),
 	}); err != nil {
 		return err
 	}
 
+	// Initialize our test runtime
+	L.Debug("deploying test runtime")
+	for _, src := range [][]byte{runnerSQL, assertSQL, mockSQL, roleSQL} {
+		if err := s.Deploy(ctx, &DeployOptions{
+			SQL:    bytes.NewReader(src),
+			Target: db,
+		}); err != nil {
+			return err
+		}
+	}
+
+	// Load our fixtures and setups
+	L.Debug("loading fixtures")
+	if err := s.testFixtures(ctx, db); err != nil {
+		return err
+	}
+	L.Debug("loading setups")
+	if err := s.testSetups(ctx, db, schema); err != nil {
+		return err
+	}
+	if err := s.testRoleAnnotations(ctx, db, schema); err != nil {
+		return err
+	}
+
+	// Reset any statistics gathered while deploying so that coverage only
+	// reflects the test run.
+	if opts.Cover {
+		if _, err := db.ExecContext(ctx, "select pg_stat_reset()"); err != nil {
+			return err
+		}
+	}
+
 	// Run tests
-	rows, err := db.QueryContext(ctx, "select * from pgunit.test_run_all()")
+	L.Debug("running tests")
+	if err := runTests(ctx, ctr, opts.Callback); err != nil {
+		return err
+	}
+
+	// Read our coverage
+	if opts.Cover {
+		L.Debug("reading function coverage")
+		cover, err := readCoverage(ctx, db, schema)
+		if err != nil {
+			return errors.Newf("error reading coverage: %w", err)
+		}
+
+		if err := opts.CoverCallback(cover); err != nil {
+			return err
+		}
+	}
+
+	// Run our query plan tests
+	L.Debug("running query plan tests")
+	plans, err := s.testPlans(ctx, db, opts.UpdatePlans)
+	if err != nil {
+		return err
+	}
+	// A failure of the plan tests doesn't stop the snapshot tests so that
+	// the failures of both are reported.
+	planErr := opts.PlanCallback(plans)
+
+	// Run our query result snapshot tests
+	L.Debug("running query snapshot tests")
+	snapshots, err := s.testSnapshots(
+		ctx, db, s.config.Test.SnapshotFormat, opts.UpdateSnapshots)
+	if err != nil {
+		return errors.CombineErrors(planErr, err)
+	}
+
+	return errors.CombineErrors(planErr, opts.SnapshotCallback(snapshots))
+}
+
+// runTests runs all the test cases and calls cb with the results. The tests
+// run in a new session that is closed once the results are read so that
+// no settings from loading fixtures or setups affect the tests, and so that
+// function statistics are flushed for coverage.
+func runTests(
+	ctx context.Context,
+	ctr dbcontainer.Container,
+	cb func(*sql.Rows) error,
+) error {
+	db, err := ctr.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, "select * from squire_test.run_all()")
 	if err != nil {
 		return err
 	}
 	defer rows.Close()
 
-	return opts.Callback(rows)
+	return cb(rows)
 }
 
+// TestContainer returns the container used for running tests. If a test
+// container was kept from a prior run (see TestPGUnitOptions.Keep) and is
+// still running, the returned container is connected to that instance.
+// Otherwise, the returned container is not created and Up must be called.
+func (s *Squire) TestContainer(ctx context.Context) (dbcontainer.Container, error) {
+	ctr, err := s.Container()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.testContainer(ctx, ctr)
+}
+
+// testContainer clones the given container to create the test container.
+// This attaches to an existing test container if one is running.
+func (s *Squire) testContainer(
+	ctx context.Context,
+	base dbcontainer.Container,
+) (dbcontainer.Container, error) {
+	tdb, err := s.testDB(ctx, base, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return tdb.Container, nil
+}
+
+// testDB returns the database to run tests in. If shadow is true, this
+// is a shadow database within the base container, which must be running.
+// Otherwise, this is the test container clone of the base container,
+// attached to an existing test container if one is running.
+func (s *Squire) testDB(
+	ctx context.Context,
+	base dbcontainer.Container,
+	shadow bool,
+) (*scratchDB, error) {
+	if shadow {
+		st, err := base.Status(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if st.State != dbcontainer.Running {
+			return nil, errors.WithDetail(
+				errors.New("dev container is not running"),
+				strings.TrimSpace(errTestShadowNotRunning),
+			)
+		}
+
+		return shadowDB(base, testCloneName)
+	}
+
+	tdb, err := cloneDB(base, testCloneName)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tdb.Adopt(ctx); err != nil {
+		return nil, err
+	}
+
+	return tdb, nil
+}
+
+const (
+	// testCloneName is the name given to the clone of the dev container
+	// (or the shadow database) that is used for tests. This is static so
+	// that a kept test container can be found again later.
+	testCloneName = "test"
+)
+
 const (
 	errCreatingTestContainer = `
 Squire creates a container clone to apply a clean version of your current
@@ -134,5 +406,11 @@ because it might have additional data or manual changes applied.
 
 The error above was received while attempting to start this test container.
 Please resolve the error and try again.
+`
+
+	errTestShadowNotRunning = `
+Running the tests in a shadow database requires the dev container to be
+running, since the shadow database is created within it. Please start the
+dev container with "squire up" or run the tests without a shadow database.
 `
 )
@@ -15,8 +15,10 @@ func TestTestPGUnit(t *testing.T) {
 	require := require.New(t)
 
 	// Build our config
-	cfg, err := config.New(config.FromString(
-		`sql_dir: "testdata/pgunit"`))
+	cfg, err := config.New(config.FromString(`
+sql_dir: "testdata/pgunit"
+test: roles: ["anon"]
+`))
 	require.NoError(err)
 
 	// Build squire
@@ -24,6 +26,7 @@ func TestTestPGUnit(t *testing.T) {
 	require.NoError(err)
 
 	// Our callback to verify results
+	var failures []string
 	cb := func(rows *sql.Rows) error {
 		cols, err := rows.Columns()
 		if err != nil {
@@ -37,14 +40,75 @@ func TestTestPGUnit(t *testing.T) {
 		count := 0
 		for rows.Next() {
 			count++
+
+			var name, message string
+			var successful, failed, erroneous bool
+			var duration interface{}
+			if err := rows.Scan(
+				&name, &successful, &failed, &erroneous, &message, &duration,
+			); err != nil {
+				return err
+			}
+
+			t.Logf("test %s: %s", name, message)
+			if !successful {
+				failures = append(failures, name+": "+message)
+			}
 		}
 		t.Logf("result count: %d", count)
 
 		return rows.Err()
 	}
 
+	// Our callback to verify coverage
+	var cover []*FunctionCoverage
+	coverCb := func(v []*FunctionCoverage) error {
+		cover = v
+		return nil
+	}
+
+	// Our callback to verify plans
+	var plans []*GoldenResult
+	planCb := func(v []*GoldenResult) error {
+		plans = v
+		return nil
+	}
+
+	// Our callback to verify snapshots
+	var snapshots []*GoldenResult
+	snapshotCb := func(v []*GoldenResult) error {
+		snapshots = v
+		return nil
+	}
+
 	// Run pgunit
 	require.NoError(sq.TestPGUnit(ctx, &TestPGUnitOptions{
-		Callback: cb,
+		Callback:         cb,
+		Cover:            true,
+		CoverCallback:    coverCb,
+		PlanCallback:     planCb,
+		SnapshotCallback: snapshotCb,
 	}))
+
+	// All our tests should pass
+	require.Empty(failures)
+
+	// Our function should be covered
+	var fc *FunctionCoverage
+	for _, v := range cover {
+		if v.Name == "account_with_default_org" {
+			fc = v
+		}
+	}
+	require.NotNil(fc)
+	require.Equal("pgunit/01-functions/func.sql", fc.File)
+	require.Greater(fc.Calls, int64(0))
+
+	// Our plans should pass
+	require.Len(plans, 1)
+	require.True(plans[0].Passed, plans[0].Diff)
+
+	// Our snapshots should pass
+	require.Len(snapshots, 1)
+	require.True(snapshots[0].Passed, snapshots[0].Diff)
 }
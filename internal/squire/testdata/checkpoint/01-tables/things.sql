@@ -0,0 +1,5 @@
+-- Created in the "app" schema because of the search_path set in 00-schema.
+CREATE TABLE things (
+  id   SERIAL PRIMARY KEY,
+  name TEXT NOT NULL
+);
@@ -0,0 +1 @@
+INSERT INTO things (name) VALUES ('one');
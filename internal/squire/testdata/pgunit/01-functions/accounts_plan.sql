@@ -0,0 +1,2 @@
+-- Looking up an account by ID should use the primary key.
+SELECT * FROM accounts WHERE id = 1;
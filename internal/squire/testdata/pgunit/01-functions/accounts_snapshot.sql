@@ -0,0 +1,2 @@
+INSERT INTO accounts DEFAULT VALUES;
+SELECT id FROM accounts ORDER BY id;
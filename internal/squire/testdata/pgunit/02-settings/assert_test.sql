@@ -0,0 +1,45 @@
+CREATE OR REPLACE FUNCTION test_case_assert_equals()
+RETURNS VOID AS $$
+BEGIN
+  PERFORM squire_test.assert_equals('dark', (SELECT value FROM settings WHERE key = 'theme'));
+  PERFORM squire_test.assert_equals('null is null', NULL::TEXT, NULL::TEXT);
+  PERFORM squire_test.assert_not_equals(1, 2);
+  PERFORM squire_test.assert_raises(
+    'SELECT squire_test.assert_equals(1, 2)', '09000');
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION test_case_assert_raises()
+RETURNS VOID AS $$
+BEGIN
+  PERFORM squire_test.assert_raises(
+    $q$INSERT INTO settings (key, value) VALUES ('theme', 'light')$q$, '23505');
+  PERFORM squire_test.assert_raises('SELECT 1/0');
+  PERFORM squire_test.assert_raises(
+    'SELECT squire_test.assert_raises($x$SELECT 1$x$)', '09000');
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION test_case_assert_rows_equal()
+RETURNS VOID AS $$
+BEGIN
+  PERFORM squire_test.assert_rows_equal(
+    $q$VALUES ('theme', 'dark')$q$,
+    $q$SELECT key, value FROM settings WHERE key = 'theme'$q$);
+  PERFORM squire_test.assert_raises(
+    $q$SELECT squire_test.assert_rows_equal('VALUES (1), (2)', 'VALUES (1)')$q$,
+    '09000');
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION test_case_assert_schema()
+RETURNS VOID AS $$
+BEGIN
+  PERFORM squire_test.assert_has_column('settings', 'value');
+  PERFORM squire_test.assert_has_column('settings', 'key', 'text');
+  PERFORM squire_test.assert_has_index('settings', 'settings_pkey');
+  PERFORM squire_test.assert_has_index('settings', ARRAY['key']);
+  PERFORM squire_test.assert_raises(
+    $q$SELECT squire_test.assert_has_column('settings', 'nope')$q$, '09000');
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,22 @@
+CREATE OR REPLACE FUNCTION test_case_mock_a_function()
+RETURNS VOID AS $$
+BEGIN
+  PERFORM squire_test.mock_function('setting_value(text)',
+    $q$ SELECT 'mocked'::text $q$);
+  PERFORM squire_test.assert_equals('mocked', setting_value('theme'));
+
+  PERFORM squire_test.restore_function('setting_value(text)');
+  PERFORM squire_test.assert_equals('dark', setting_value('theme'));
+
+  -- Mock again and leave it, this should be restored for the next test.
+  PERFORM squire_test.mock_function('setting_value(text)',
+    $q$ BEGIN RETURN 'mocked'; END; $q$, 'plpgsql');
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION test_case_mock_b_restored()
+RETURNS VOID AS $$
+BEGIN
+  PERFORM squire_test.assert_equals('dark', setting_value('theme'));
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,9 @@
+CREATE TABLE settings (
+  key   TEXT PRIMARY KEY,
+  value TEXT NOT NULL
+);
+
+CREATE FUNCTION setting_value(_key TEXT)
+RETURNS TEXT AS $$
+  SELECT value FROM settings WHERE key = _key;
+$$ LANGUAGE sql STABLE;
@@ -0,0 +1,2 @@
+INSERT INTO settings (key, value) VALUES ('locale', 'en');
+INSERT INTO settings (key, value) VALUES ('timezone', 'UTC');
@@ -0,0 +1,23 @@
+CREATE OR REPLACE FUNCTION test_case_settings_a_fixture()
+RETURNS VOID AS $$
+BEGIN
+  -- Fixtures and setups should both be loaded.
+  PERFORM pgunit.test_assertTrue('fixture should be loaded',
+    EXISTS (SELECT 1 FROM settings WHERE key = 'theme'));
+  PERFORM pgunit.test_assertTrue('setup should be loaded',
+    EXISTS (SELECT 1 FROM settings WHERE key = 'locale'));
+
+  -- This should be rolled back before the next test.
+  INSERT INTO settings (key, value) VALUES ('leak', 'true');
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION test_case_settings_b_isolated()
+RETURNS VOID AS $$
+BEGIN
+  PERFORM pgunit.test_assertTrue('previous test should be rolled back',
+    NOT EXISTS (SELECT 1 FROM settings WHERE key = 'leak'));
+  PERFORM pgunit.test_assertTrue('setup should be loaded',
+    EXISTS (SELECT 1 FROM settings WHERE key = 'timezone'));
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,18 @@
+CREATE TABLE notes (
+  id    INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  owner TEXT NOT NULL,
+  body  TEXT NOT NULL
+);
+
+ALTER TABLE notes ENABLE ROW LEVEL SECURITY;
+GRANT SELECT ON notes TO app_user, anon;
+
+-- Users can only see their own notes.
+CREATE POLICY notes_owner ON notes FOR SELECT TO app_user
+  USING (owner = current_setting('request.jwt.claim.sub', true));
+
+-- Counts every note, regardless of the policies for the caller.
+CREATE FUNCTION note_count()
+RETURNS BIGINT AS $$
+  SELECT count(*) FROM notes;
+$$ LANGUAGE sql STABLE SECURITY DEFINER SET search_path = public, pg_temp;
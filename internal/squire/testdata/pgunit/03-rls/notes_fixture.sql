@@ -0,0 +1,3 @@
+INSERT INTO notes (owner, body) VALUES
+  ('alice', 'hello from alice'),
+  ('bob', 'hello from bob');
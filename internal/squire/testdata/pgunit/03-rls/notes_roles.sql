@@ -0,0 +1 @@
+CREATE ROLE app_user NOLOGIN;
@@ -0,0 +1,41 @@
+-- squire:test-role app_user request.jwt.claim.sub=alice
+CREATE OR REPLACE FUNCTION test_case_notes_owner()
+RETURNS VOID AS $$
+BEGIN
+  PERFORM squire_test.assert_equals('app_user', current_user::TEXT);
+  PERFORM squire_test.assert_rows_equal(
+    $q$VALUES ('hello from alice')$q$,
+    $q$SELECT body FROM notes$q$);
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION test_case_notes_anon()
+RETURNS VOID AS $$
+BEGIN
+  -- There is no policy for anon so it sees nothing.
+  PERFORM squire_test.set_role('anon');
+  PERFORM squire_test.assert_equals(0::BIGINT, (SELECT count(*) FROM notes));
+  PERFORM squire_test.reset_role();
+
+  -- The table owner bypasses row-level security.
+  PERFORM squire_test.assert_equals(2::BIGINT, (SELECT count(*) FROM notes));
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION test_case_notes_mock_definer()
+RETURNS VOID AS $$
+BEGIN
+  -- The mock keeps the attributes of the function, so it still runs as
+  -- the owner and sees every note.
+  PERFORM squire_test.mock_function('note_count()',
+    $q$ SELECT count(*) + 0 FROM notes $q$);
+  PERFORM squire_test.assert_rows_equal(
+    $q$VALUES (true, 's'::"char", '{"search_path=public, pg_temp"}'::TEXT[])$q$,
+    $q$SELECT prosecdef, provolatile, proconfig FROM pg_proc
+       WHERE oid = 'note_count()'::REGPROCEDURE$q$);
+
+  PERFORM squire_test.set_role('app_user', '{"request.jwt.claim.sub": "alice"}');
+  PERFORM squire_test.assert_equals(2::BIGINT, note_count());
+  PERFORM squire_test.reset_role();
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,259 @@
+package squire
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/mitchellh/squire/internal/dbcontainer"
+)
+
+// upgradeTestContainer applies the schema from a previous version to the
+// test container and then upgrades it to the current schema by deploying
+// the output of Diff. Finally, the test files are applied. The base container
+// is the dev container that Diff uses to create its own clone.
+func (s *Squire) upgradeTestContainer(
+	ctx context.Context,
+	base, ctr dbcontainer.Container,
+	from string,
+) error {
+	L := s.logger.Named("upgrade").With("from", from)
+
+	// Get the previous schema
+	L.Debug("generating previous schema")
+	old, err := s.upgradeSchema(ctx, from)
+	if err != nil {
+		return err
+	}
+
+	// Reset to the previous schema
+	L.Debug("applying previous schema")
+	if err := s.Reset(ctx, &ResetOptions{
+		Container: ctr,
+		Schema:    bytes.NewReader(old),
+	}); err != nil {
+		return errors.WithDetail(
+			errors.Newf("error applying previous schema to test container: %w", err),
+			strings.TrimSpace(errCreatingTestContainer),
+		)
+	}
+
+	// Diff from the previous schema to the current schema
+	L.Debug("diffing previous schema to current schema")
+	var diff bytes.Buffer
+	if err := s.Diff(ctx, &DiffOptions{
+		Container: base,
+		TargetURI: ctr.ConnURI(),
+		Output:    &diff,
+	}); err != nil {
+		return errors.Newf("error diffing previous schema: %w", err)
+	}
+
+	// Apply the diff
+	if diff.Len() > 0 {
+		L.Debug("applying diff", "diff", diff.String())
+		if err := s.Deploy(ctx, &DeployOptions{
+			SQL:       &diff,
+			TargetURI: ctr.ConnURI(),
+		}); err != nil {
+			return errors.WithDetail(
+				errors.Newf("error applying upgrade diff: %w", err),
+				strings.TrimSpace(errUpgradeApply),
+			)
+		}
+	} else {
+		L.Debug("empty diff, previous schema matches current schema")
+	}
+
+	// Apply our tests
+	L.Debug("applying tests")
+	var tests bytes.Buffer
+	if err := s.Schema(&SchemaOptions{
+		Output:    &tests,
+		Tests:     true,
+		TestsOnly: true,
+	}); err != nil {
+		return err
+	}
+	if tests.Len() > 0 {
+		if err := s.Deploy(ctx, &DeployOptions{
+			SQL:       &tests,
+			TargetURI: ctr.ConnURI(),
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// upgradeSchema returns the schema (without tests) for the given previous
+// version. The version can be a path to a SQL file (such as a pg_dump of
+// production) or a git ref. For a git ref, the SQL directory is read from
+// the repository at that ref and built.
+func (s *Squire) upgradeSchema(ctx context.Context, from string) ([]byte, error) {
+	L := s.logger.Named("upgrade").With("from", from)
+
+	// If it is a file, then we use it directly.
+	if fi, err := os.Stat(from); err == nil && !fi.IsDir() {
+		L.Debug("upgrading from a SQL file")
+		return ioutil.ReadFile(from)
+	}
+
+	L.Debug("upgrading from a git ref")
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		return nil, errors.WithDetail(
+			errors.Newf("git could not be found: %w", err),
+			strings.TrimSpace(errUpgradeGitNotFound),
+		)
+	}
+
+	rootDir, rootFile, err := s.sqlRoot()
+	if err != nil {
+		return nil, err
+	}
+	sqlDir := filepath.Join(rootDir, rootFile)
+
+	// Determine the path of our SQL directory within the repository.
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, gitPath, "rev-parse", "--show-toplevel")
+	cmd.Dir = sqlDir
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, errors.WithDetail(
+			errors.Newf("error finding git repository: %w", err),
+			strings.TrimSpace(errUpgradeGit),
+		)
+	}
+	repoDir := strings.TrimSpace(out.String())
+
+	// Resolve symlinks on both sides since git reports the real path.
+	if v, err := filepath.EvalSymlinks(repoDir); err == nil {
+		repoDir = v
+	}
+	if v, err := filepath.EvalSymlinks(sqlDir); err == nil {
+		sqlDir = v
+	}
+	relDir, err := filepath.Rel(repoDir, sqlDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Extract the SQL directory at the ref into a temporary directory.
+	td, err := ioutil.TempDir("", "squire-upgrade")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(td)
+
+	out.Reset()
+	cmd = exec.CommandContext(ctx, gitPath,
+		"archive", "--format=tar", from, "--", filepath.ToSlash(relDir))
+	cmd.Dir = repoDir
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, errors.WithDetailf(
+			errors.Newf("error reading %q from git ref %q: %w", relDir, from, err),
+			strings.TrimSpace(errUpgradeGitRef),
+			from,
+		)
+	}
+	if err := untar(&out, td); err != nil {
+		return nil, err
+	}
+
+	// Build the schema as it was at that ref.
+	cfg := *s.config
+	cfg.SQLDir = filepath.Join(td, relDir)
+	old := &Squire{logger: s.logger, config: &cfg}
+
+	var buf bytes.Buffer
+	if err := old.Schema(&SchemaOptions{Output: &buf}); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// untar extracts the regular files and directories in the tar stream r
+// into the directory dst.
+func untar(r io.Reader, dst string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// Protect against paths that escape our destination.
+		path := filepath.Join(dst, filepath.FromSlash(hdr.Name))
+		if !strings.HasPrefix(path, filepath.Clean(dst)+string(filepath.Separator)) {
+			return errors.Newf("invalid path in archive: %s", hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+
+			f, err := os.Create(path)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+const (
+	errUpgradeGitNotFound = `
+The program "git" could not be found. An upgrade test was requested from
+a previous version that is not a file, so Squire attempted to treat it as
+a git ref. Please install git or specify a path to a SQL file.
+`
+
+	errUpgradeGit = `
+An upgrade test was requested from a previous version that is not a file,
+so Squire attempted to treat it as a git ref. However, the SQL directory
+does not appear to be within a git repository. Please specify a path to a
+SQL file (such as a schema dump) instead.
+`
+
+	errUpgradeGitRef = `
+An upgrade test was requested from a previous version that is not a file,
+so Squire attempted to treat it as a git ref. The SQL directory could not be
+read at the ref %q. Please verify the ref exists and that the SQL directory
+existed at that ref. If you meant to specify a file, verify the path.
+`
+
+	errUpgradeApply = `
+The diff from the previous version of the schema to the current schema
+failed to apply. This means that deploying the current schema with
+"squire deploy" to a database with the previous schema would likely fail
+the same way. Inspect the error above to determine next steps.
+`
+)
@@ -0,0 +1,82 @@
+package squire
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mitchellh/squire/internal/config"
+)
+
+func TestUpgradeSchema_file(t *testing.T) {
+	require := require.New(t)
+
+	cfg, err := config.New(config.FromString(
+		`sql_dir: "testdata/schema"`))
+	require.NoError(err)
+	sq, err := New(WithConfig(cfg))
+	require.NoError(err)
+
+	bs, err := sq.upgradeSchema(context.Background(), "testdata/schema/00-start/a.sql")
+	require.NoError(err)
+
+	expected, err := ioutil.ReadFile("testdata/schema/00-start/a.sql")
+	require.NoError(err)
+	require.Equal(expected, bs)
+}
+
+func TestUpgradeSchema_git(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	require := require.New(t)
+
+	// Create a repository with our schema committed
+	td, err := ioutil.TempDir("", "squire")
+	require.NoError(err)
+	defer os.RemoveAll(td)
+
+	sqlDir := filepath.Join(td, "db", "sql")
+	require.NoError(os.MkdirAll(filepath.Join(sqlDir, "00-start"), 0755))
+	require.NoError(ioutil.WriteFile(
+		filepath.Join(sqlDir, "00-start", "a.sql"),
+		[]byte("CREATE TABLE old ();\n"), 0644))
+
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{
+			"-c", "user.name=test", "-c", "user.email=test@example.com",
+		}, args...)...)
+		cmd.Dir = td
+		out, err := cmd.CombinedOutput()
+		require.NoError(err, string(out))
+	}
+	git("init", "-q")
+	git("add", "-A")
+	git("commit", "-q", "-m", "old")
+
+	// Change the schema after the commit
+	require.NoError(ioutil.WriteFile(
+		filepath.Join(sqlDir, "00-start", "a.sql"),
+		[]byte("CREATE TABLE new ();\n"), 0644))
+
+	cfg, err := config.New(config.FromString(`sql_dir: "` + sqlDir + `"`))
+	require.NoError(err)
+	sq, err := New(WithConfig(cfg))
+	require.NoError(err)
+
+	// The schema at HEAD should be the old schema
+	bs, err := sq.upgradeSchema(context.Background(), "HEAD")
+	require.NoError(err)
+	require.Contains(string(bs), "CREATE TABLE old")
+	require.NotContains(string(bs), "CREATE TABLE new")
+
+	// An invalid ref should error
+	_, err = sq.upgradeSchema(context.Background(), "nope")
+	require.Error(err)
+}
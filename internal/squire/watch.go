@@ -0,0 +1,271 @@
+package squire
+
+import (
+	"context"
+	"database/sql"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/mitchellh/squire/internal/dbcontainer"
+)
+
+type WatchOptions struct {
+	// Container is the dev container to reset on every change. If this
+	// is nil, the default Container is used.
+	Container dbcontainer.Container
+
+	// Test, if true, will run the tests with TestPGUnit after every
+	// successful reset. The test database is created once and reused for
+	// every run so that subsequent runs are fast, and is destroyed when
+	// Watch returns.
+	Test bool
+
+	// Shadow, if true, runs the tests in a shadow database within the dev
+	// container rather than a separate test container. See
+	// TestPGUnitOptions.Shadow.
+	Shadow bool
+
+	// Debounce is the time to wait after a change before resetting. Any
+	// additional changes within this time restart the wait, so that saving
+	// many files at once only results in a single reset. Defaults to 250ms.
+	Debounce time.Duration
+
+	// StartCallback is called when a reset begins with the files that
+	// changed. The list of files is empty for the initial reset.
+	StartCallback func(files []string)
+
+	// Callback is called with the result of every reset, including the
+	// initial reset when Watch starts.
+	Callback func(*WatchResult)
+}
+
+// WatchResult is the result of a single reset (and test run) in Watch.
+type WatchResult struct {
+	// Files are the files that changed to trigger this reset, relative to
+	// the parent of the SQL directory. This is empty for the initial reset.
+	Files []string
+
+	// ResetErr is non-nil if the schema failed to build or apply. If this
+	// is set, the tests were not run.
+	ResetErr error
+
+	// TestErr is non-nil if the tests could not be run.
+	TestErr error
+
+	// Tested is true if tests were run. Passed and Failed are the number
+	// of test cases that passed and failed (including errors), as well as
+	// plan and snapshot tests. Failures are the names of the failed tests
+	// along with their message.
+	Tested   bool
+	Passed   int
+	Failed   int
+	Failures []string
+
+	// Duration is how long the reset and tests took.
+	Duration time.Duration
+}
+
+// Watch watches the SQL directory for changes and resets the dev
+// database (and optionally runs the tests) every time a SQL file changes.
+// Errors from building, applying, or testing the schema are reported
+// to the callback and do not stop the watch. This blocks until the context
+// is cancelled or the watch itself fails.
+func (s *Squire) Watch(ctx context.Context, opts *WatchOptions) error {
+	L := s.logger.Named("watch")
+
+	var err error
+	if opts.Container == nil {
+		opts.Container, err = s.Container()
+		if err != nil {
+			return err
+		}
+	}
+	if opts.Debounce == 0 {
+		opts.Debounce = 250 * time.Millisecond
+	}
+	if opts.StartCallback == nil {
+		opts.StartCallback = func([]string) {}
+	}
+	if opts.Callback == nil {
+		opts.Callback = func(*WatchResult) {}
+	}
+
+	rootDir, rootFile, err := s.sqlRoot()
+	if err != nil {
+		return err
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	if err := watchDir(w, filepath.Join(rootDir, rootFile)); err != nil {
+		return err
+	}
+
+	// If we're testing, we create the test database once, reuse it for
+	// every run, and destroy it once we're done watching. Shadow databases
+	// have a unique name, so they can't be found again later.
+	var tdb *scratchDB
+	if opts.Test {
+		tdb, err = s.testDB(ctx, opts.Container, opts.Shadow)
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			if err := tdb.Destroy(); err != nil {
+				L.Error("error destroying test database, may still be dangling",
+					"err", err)
+			}
+		}()
+	}
+
+	// Initial reset
+	opts.StartCallback(nil)
+	opts.Callback(s.watchRun(ctx, opts, tdb, nil))
+
+	changed := map[string]struct{}{}
+	timer := time.NewTimer(opts.Debounce)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case err := <-w.Errors:
+			L.Warn("error watching files", "err", err)
+
+		case ev := <-w.Events:
+			L.Trace("file event", "event", ev.String())
+
+			// New directories need to be watched as well.
+			if ev.Op&fsnotify.Create != 0 {
+				if err := watchDir(w, ev.Name); err != nil {
+					L.Trace("not watching new path", "path", ev.Name, "err", err)
+				}
+			}
+
+			if filepath.Ext(ev.Name) != ".sql" {
+				continue
+			}
+
+			rel, err := filepath.Rel(rootDir, ev.Name)
+			if err != nil {
+				rel = ev.Name
+			}
+			changed[filepath.ToSlash(rel)] = struct{}{}
+			timer.Reset(opts.Debounce)
+
+		case <-timer.C:
+			files := make([]string, 0, len(changed))
+			for f := range changed {
+				files = append(files, f)
+			}
+			sort.Strings(files)
+			changed = map[string]struct{}{}
+
+			L.Debug("files changed", "files", files)
+			opts.StartCallback(files)
+			opts.Callback(s.watchRun(ctx, opts, tdb, files))
+		}
+	}
+}
+
+// watchRun performs a single reset and test run for Watch. The tests are
+// run in tdb if opts.Test is set.
+func (s *Squire) watchRun(
+	ctx context.Context,
+	opts *WatchOptions,
+	tdb *scratchDB,
+	files []string,
+) *WatchResult {
+	start := time.Now()
+	result := &WatchResult{Files: files}
+	defer func() { result.Duration = time.Since(start) }()
+
+	result.ResetErr = s.Reset(ctx, &ResetOptions{
+		Container:   opts.Container,
+		Incremental: true,
+	})
+	if result.ResetErr != nil || !opts.Test {
+		return result
+	}
+
+	result.Tested = true
+	result.TestErr = s.TestPGUnit(ctx, &TestPGUnitOptions{
+		Container: opts.Container,
+		Shadow:    opts.Shadow,
+		database:  tdb,
+		Callback: func(rows *sql.Rows) error {
+			for rows.Next() {
+				var name, message string
+				var successful, failed, erroneous bool
+				var duration interface{}
+				if err := rows.Scan(
+					&name, &successful, &failed, &erroneous, &message, &duration,
+				); err != nil {
+					return err
+				}
+
+				if successful {
+					result.Passed++
+					continue
+				}
+
+				result.Failed++
+				result.Failures = append(result.Failures, name+": "+message)
+			}
+
+			return rows.Err()
+		},
+		PlanCallback:     watchGoldenCallback(result),
+		SnapshotCallback: watchGoldenCallback(result),
+	})
+
+	return result
+}
+
+// watchGoldenCallback returns a callback for plan or snapshot results that
+// records any mismatches as failures on the result.
+func watchGoldenCallback(result *WatchResult) func([]*GoldenResult) error {
+	return func(rs []*GoldenResult) error {
+		for _, r := range rs {
+			if r.Passed {
+				result.Passed++
+				continue
+			}
+
+			result.Failed++
+			result.Failures = append(result.Failures,
+				r.File+": does not match "+r.Golden)
+		}
+
+		return nil
+	}
+}
+
+// watchDir adds a watch for the directory and all directories within it.
+// Hidden directories (such as ".git") are skipped.
+func watchDir(w *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if p != root && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+
+		return w.Add(p)
+	})
+}
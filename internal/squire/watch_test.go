@@ -0,0 +1,126 @@
+package squire
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mitchellh/squire/internal/config"
+)
+
+func TestWatchDir(t *testing.T) {
+	require := require.New(t)
+
+	td, err := ioutil.TempDir("", "squire-watch")
+	require.NoError(err)
+	defer os.RemoveAll(td)
+
+	nested := filepath.Join(td, "01-schema", "nested")
+	hidden := filepath.Join(td, ".hidden")
+	require.NoError(os.MkdirAll(nested, 0755))
+	require.NoError(os.MkdirAll(hidden, 0755))
+
+	w, err := fsnotify.NewWatcher()
+	require.NoError(err)
+	defer w.Close()
+	require.NoError(watchDir(w, td))
+
+	// Changes in hidden directories are not seen
+	require.NoError(ioutil.WriteFile(filepath.Join(hidden, "a.sql"), nil, 0644))
+
+	// Changes in nested directories are
+	path := filepath.Join(nested, "a.sql")
+	require.NoError(ioutil.WriteFile(path, nil, 0644))
+
+	select {
+	case ev := <-w.Events:
+		require.Equal(path, ev.Name)
+	case err := <-w.Errors:
+		require.NoError(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+}
+
+func TestWatch_shadow(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	td, err := ioutil.TempDir("", "squire-watch")
+	require.NoError(err)
+	defer os.RemoveAll(td)
+	path := filepath.Join(td, "00-schema", "a.sql")
+	require.NoError(os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(ioutil.WriteFile(path, []byte("CREATE TABLE a (id int);\n"), 0644))
+
+	cfg, err := config.New(config.FromString(fmt.Sprintf("sql_dir: %q", td)))
+	require.NoError(err)
+	sq, err := New(WithConfig(cfg))
+	require.NoError(err)
+	ctr, err := sq.Container()
+	require.NoError(err)
+
+	// Spin up the container
+	require.NoError(ctr.Up(ctx))
+	defer ctr.Down(ctx)
+
+	// Watch until the second run completes. The first run is the initial
+	// reset, the second is triggered by changing a file.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var results []*WatchResult
+	var shadows []string
+	var shadowsErr, writeErr error
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- sq.Watch(ctx, &WatchOptions{
+			Container: ctr,
+			Test:      true,
+			Shadow:    true,
+			Debounce:  10 * time.Millisecond,
+			Callback: func(r *WatchResult) {
+				results = append(results, r)
+				shadows, shadowsErr = testShadowDatabases(ctr)
+				if len(results) == 1 && shadowsErr == nil {
+					writeErr = ioutil.WriteFile(path,
+						[]byte("CREATE TABLE b (id int);\n"), 0644)
+					if writeErr == nil {
+						return
+					}
+				}
+
+				cancel()
+			},
+		})
+	}()
+
+	select {
+	case err := <-errCh:
+		require.NoError(err)
+	case <-time.After(1 * time.Minute):
+		t.Fatal("watch didn't complete")
+	}
+
+	require.NoError(shadowsErr)
+	require.NoError(writeErr)
+	require.Len(results, 2)
+	for _, r := range results {
+		require.NoError(r.ResetErr)
+		require.NoError(r.TestErr)
+		require.True(r.Tested)
+	}
+
+	// Every run uses the same shadow database, and it is dropped once
+	// the watch is over.
+	require.Len(shadows, 1)
+	shadows, err = testShadowDatabases(ctr)
+	require.NoError(err)
+	require.Empty(shadows)
+}